@@ -0,0 +1,589 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// --- 配置模型 ---
+
+// ClashConfig 是生成结果的结构化模型，由 buildConfig 构建、renderConfig 输出为 YAML
+type ClashConfig struct {
+	ProviderOnly bool
+	Comments     []string // 写在文件开头的注释，如订阅流量信息
+	General      *GeneralSettings
+	Proxies      []Node
+	Groups       []ProxyGroup
+
+	ProxyProviders []ProxyProvider
+	HealthCheck    HealthCheck // 节点集的健康检查参数
+
+	RuleProviderType     string
+	RuleProviderInterval int
+	RuleProviders        []RuleList
+	Rules                []Rule
+
+	RuleLists    int      // 需要下载内联的规则列表数
+	MissingLists []string // 获取失败、未包含的规则列表
+}
+
+// ProxyGroup 是展开成员后的策略组
+type ProxyGroup struct {
+	Name      string
+	Type      string
+	Proxies   []string
+	URL       string
+	Interval  int
+	Timeout   int
+	Tolerance int
+
+	Lazy           *bool
+	MaxFailedTimes int
+	Strategy       string
+
+	Use           []string // 引用的节点集 (proxy-providers)
+	Filter        string   // 只选用节点集中名称匹配的节点
+	ExcludeFilter string
+}
+
+// 多国分组的默认测速参数
+var regionGroupTemplate = GroupTemplate{Type: "url-test", URL: "http://www.gstatic.com/generate_204", Interval: 300, Tolerance: 50}
+
+// HealthCheck 统一覆盖所有测速组 (url-test/fallback/load-balance) 的健康检查参数，
+// 零值项沿用模板中的设置
+type HealthCheck struct {
+	URL            string
+	Interval       int
+	Timeout        int
+	Tolerance      int
+	Lazy           *bool
+	MaxFailedTimes int
+	Strategy       string
+}
+
+// 测速地址预设；gstatic 在国内部分地区不稳定，cn 使用国内可直连的 204 地址，
+// 便于对比直连与代理延迟
+var testURLPresets = map[string]string{
+	"gstatic":    "http://www.gstatic.com/generate_204",
+	"cloudflare": "http://cp.cloudflare.com/generate_204",
+	"apple":      "http://captive.apple.com/generate_204",
+	"cn":         "http://connect.rom.miui.com/generate_204",
+}
+
+// resolveTestURL 把预设名转换为地址，其他值原样返回
+func resolveTestURL(s string) string {
+	if u, ok := testURLPresets[strings.ToLower(s)]; ok {
+		return u
+	}
+	return s
+}
+
+func (h HealthCheck) apply(g *ProxyGroup) {
+	if g.Type == "select" {
+		return
+	}
+	if h.URL != "" {
+		g.URL = h.URL
+	}
+	if h.Interval > 0 {
+		g.Interval = h.Interval
+	}
+	if h.Timeout > 0 {
+		g.Timeout = h.Timeout
+	}
+	if h.Tolerance > 0 {
+		g.Tolerance = h.Tolerance
+	}
+	if h.Lazy != nil {
+		g.Lazy = h.Lazy
+	}
+	if h.MaxFailedTimes > 0 {
+		g.MaxFailedTimes = h.MaxFailedTimes
+	}
+	if g.Type == "load-balance" {
+		if h.Strategy != "" {
+			g.Strategy = h.Strategy
+		}
+		if g.Strategy == "" {
+			g.Strategy = "consistent-hashing"
+		}
+	}
+}
+
+func buildConfig(nodes []Node, c ModeConfig, customRules []CustomRule, fetcher *RuleFetcher) *ClashConfig {
+	cfg := &ClashConfig{Proxies: nodes, General: c.General, Comments: c.Comments}
+	if c.IsProvider {
+		cfg.ProviderOnly = true
+		return cfg
+	}
+	t := c.Template
+	groups, rulesets := applyServices(t, c.Services)
+	groups = mergeGroupTemplates(groups, c.ExtraGroups)
+
+	// 使用节点集时只内联流量信息占位节点，输入的节点仅用于决定生成哪些地区分组
+	var use []string
+	inline := nodes
+	if len(c.ProxyProviders) > 0 {
+		use = providerNames(c.ProxyProviders)
+		cfg.ProxyProviders, cfg.HealthCheck = c.ProxyProviders, c.HealthCheck
+		inline = nil
+		for _, n := range nodes {
+			if n.Region == infoRegion {
+				inline = append(inline, n)
+			}
+		}
+		cfg.Proxies = inline
+	}
+
+	// --- 策略组 ---
+	var regionCodes []string
+	var regionNodes map[string][]Node
+	for _, gt := range groups {
+		for _, m := range gt.Members {
+			if m == "!!REGIONS" && regionNodes == nil {
+				regionNodes = classifyNodes(nodes)
+				regionCodes = regionOrder(regionNodes)
+				// 没有输入节点时无从得知节点集里有哪些地区，使用常用地区并以其他地区兜底
+				if use != nil && len(regionCodes) == 0 {
+					regionCodes = append(append([]string(nil), preferredRegions...), "Other")
+				}
+			}
+		}
+	}
+
+	regionInserted := false
+	for i, gt := range groups {
+		cfg.Groups = append(cfg.Groups, expandGroup(gt, inline, regionCodes, use))
+		// 多国分组紧跟在引用它的组及其后的测速组之后
+		if regionNodes != nil && !regionInserted && groupMentionsRegions(groups[:i+1]) &&
+			(i+1 == len(groups) || groups[i+1].Type == "select") {
+			for _, code := range regionCodes {
+				if use != nil {
+					cfg.Groups = append(cfg.Groups, providerRegionGroup(code, regionCodes, use))
+					continue
+				}
+				rg := regionGroupTemplate
+				rg.Name = getCountryGroupName(code)
+				g := groupFromTemplate(rg)
+				for _, n := range regionNodes[code] {
+					g.Proxies = append(g.Proxies, n.Name)
+				}
+				cfg.Groups = append(cfg.Groups, g)
+			}
+			regionInserted = true
+		}
+	}
+	cfg.Groups = pruneRegionGroups(cfg.Groups, groups)
+	if c.SourceGroups {
+		if use != nil {
+			cfg.Groups = addSourceGroups(cfg.Groups, providerGroups(c.ProxyProviders))
+		} else {
+			cfg.Groups = addSourceGroups(cfg.Groups, sourceGroups(nodes))
+		}
+	}
+	for i := range cfg.Groups {
+		c.HealthCheck.apply(&cfg.Groups[i])
+	}
+
+	// --- 规则 ---
+	// 自定义规则的策略组必须存在，否则 Clash 会拒绝加载整个配置
+	policies := map[string]bool{}
+	for _, g := range cfg.Groups {
+		policies[g.Name] = true
+	}
+	var custom []Rule
+	var customFinal *Rule
+	for _, cr := range customRules {
+		r := cr.Rule
+		if !policies[r.Target] && !builtinPolicies[r.Target] {
+			fmt.Printf("❌ 自定义规则第 %d 行: 策略组 %q 不存在，已忽略\n", cr.Line, r.Target)
+			continue
+		}
+		if r.Type == "MATCH" {
+			customFinal = &r
+			continue
+		}
+		custom = append(custom, r)
+	}
+	if !c.CustomRulesAfter {
+		cfg.Rules = append(cfg.Rules, custom...)
+	}
+
+	src := c.RuleSource
+	if src == nil {
+		src = ruleSources[0]
+	}
+	cfg.RuleProviderType = c.RuleProviderType
+	cfg.RuleProviderInterval = c.RuleProviderInterval
+
+	// 先收集需要下载的列表
+	var urls []string
+	seen := map[string]bool{}
+	for _, rs := range rulesets {
+		lists, _, _ := resolveRuleset(rs.Source, src)
+		for _, l := range lists {
+			if !seen[l.URL] {
+				seen[l.URL] = true
+				urls = append(urls, l.URL)
+			}
+		}
+	}
+	var contents map[string]string
+	if c.RuleProviderType != "http" {
+		contents = fetcher.downloadRules(urls)
+		cfg.RuleLists = len(urls)
+		for _, u := range urls {
+			if _, ok := contents[u]; !ok {
+				cfg.MissingLists = append(cfg.MissingLists, path.Base(u))
+			}
+		}
+	}
+
+	var final *Rule
+	providerSeen := map[string]bool{}
+	for _, rs := range rulesets {
+		lists, role, inline := resolveRuleset(rs.Source, src)
+		if inline != "" {
+			r, err := inlineRule(inline, rs.Group)
+			if err != nil {
+				fmt.Printf("⚠️  模板 %s 中的规则 %s 无效: %v\n", t.File, inline, err)
+				continue
+			}
+			r.From = "模板内联规则"
+			if r.Type == "MATCH" {
+				final = &r
+				continue
+			}
+			cfg.Rules = append(cfg.Rules, r)
+			continue
+		}
+		for _, l := range lists {
+			if c.RuleProviderType == "" {
+				rules, _ := parseRuleList(contents[l.URL], l)
+				// 与前面自定义规则重复或被其覆盖的条目由 compileRules 统一剔除
+				for _, r := range rules {
+					r.Target = rs.Group
+					r.From = ruleProviderName(l.URL)
+					// 国内 IP 列表不触发 DNS 解析，避免域名请求在此处泄露
+					if role == RoleDirectIP && r.IsIP() {
+						r.addOption("no-resolve")
+					}
+					cfg.Rules = append(cfg.Rules, r)
+				}
+				continue
+			}
+			if c.RuleProviderType == "file" && contents[l.URL] == "" {
+				continue
+			}
+			if !providerSeen[l.URL] {
+				providerSeen[l.URL] = true
+				cfg.RuleProviders = append(cfg.RuleProviders, l)
+			}
+			rule := Rule{Type: "RULE-SET", Value: ruleProviderName(l.URL), Target: rs.Group, From: "rule-providers"}
+			if role == RoleDirectIP {
+				rule.Options = []string{"no-resolve"}
+			}
+			cfg.Rules = append(cfg.Rules, rule)
+		}
+	}
+	if c.CustomRulesAfter {
+		cfg.Rules = append(cfg.Rules, custom...)
+	}
+	// 自定义的 MATCH 取代模板中的兜底规则
+	if customFinal != nil {
+		final = customFinal
+	}
+	var stats []CompileStat
+	cfg.Rules, stats = compileRules(cfg.Rules)
+	printCompileReport(stats)
+	if final != nil {
+		cfg.Rules = append(cfg.Rules, *final)
+	}
+	return cfg
+}
+
+// inlineRule 把模板里的 []TYPE,value[,option] 转换为完整规则
+func inlineRule(spec, group string) (Rule, error) {
+	r, err := parseRule(spec, false)
+	r.Target = group
+	return r, err
+}
+
+// mergeGroupTemplates 把用户自定义组插入到模板第一个组 (通常是 🚀 节点选择) 之后；
+// 与模板同名的组直接替换模板中的定义
+func mergeGroupTemplates(base, extra []GroupTemplate) []GroupTemplate {
+	if len(extra) == 0 {
+		return base
+	}
+	merged := append([]GroupTemplate(nil), base...)
+	var added []GroupTemplate
+	for _, g := range extra {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == g.Name {
+				merged[i] = g
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			added = append(added, g)
+		}
+	}
+	if len(merged) == 0 {
+		return added
+	}
+	return append(merged[:1], append(added, merged[1:]...)...)
+}
+
+func groupMentionsRegions(gs []GroupTemplate) bool {
+	for _, g := range gs {
+		for _, m := range g.Members {
+			if m == "!!REGIONS" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func groupFromTemplate(gt GroupTemplate) ProxyGroup {
+	return ProxyGroup{
+		Name:      gt.Name,
+		Type:      gt.Type,
+		URL:       gt.URL,
+		Interval:  gt.Interval,
+		Timeout:   gt.Timeout,
+		Tolerance: gt.Tolerance,
+
+		Lazy:           gt.Lazy,
+		MaxFailedTimes: gt.MaxFailedTimes,
+		Strategy:       gt.Strategy,
+	}
+}
+
+// expandGroup 把模板成员展开为具体的组名与节点名；use 非空时节点改由节点集提供，
+// 正则和 !!REGION= 成员转为 filter
+func expandGroup(gt GroupTemplate, nodes []Node, regionCodes []string, use []string) ProxyGroup {
+	g := groupFromTemplate(gt)
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			g.Proxies = append(g.Proxies, name)
+		}
+	}
+	var filters []string
+	for _, m := range gt.Members {
+		switch {
+		case strings.HasPrefix(m, "[]"):
+			add(m[2:])
+		case m == "!!REGIONS":
+			for _, code := range regionCodes {
+				add(getCountryGroupName(code))
+			}
+		case strings.HasPrefix(m, "!!REGION="):
+			want := map[string]bool{}
+			for _, code := range strings.Split(strings.TrimPrefix(m, "!!REGION="), "|") {
+				code = strings.ToUpper(strings.TrimSpace(code))
+				want[code] = true
+				if use != nil {
+					filters = append(filters, regionFilter(code))
+				}
+			}
+			for _, n := range nodes {
+				code := n.Region
+				if code == "" {
+					code = detectRegion(n.Name)
+				}
+				if want[code] {
+					add(n.Name)
+				}
+			}
+		default:
+			re, err := regexp.Compile(m)
+			if err != nil {
+				continue
+			}
+			if use != nil {
+				if m == ".*" {
+					m = ""
+				}
+				filters = append(filters, m)
+			}
+			for _, n := range nodes {
+				// 流量信息占位节点连不通，只放进手动选择的组，不参与测速
+				if n.Region == infoRegion && g.Type != "select" {
+					continue
+				}
+				if re.MatchString(n.Name) {
+					add(n.Name)
+				}
+			}
+		}
+	}
+	if len(filters) > 0 {
+		g.Use, g.Filter = use, joinFilters(filters)
+	}
+	// 空组会导致 Clash 拒绝加载，与 subconverter 一样补一个 DIRECT
+	if len(g.Proxies) == 0 && len(g.Use) == 0 {
+		g.Proxies = append(g.Proxies, "DIRECT")
+	}
+	return g
+}
+
+// pruneRegionGroups 去掉只由 !!REGION= 组成、却没有匹配到任何节点的组，
+// 并从其他组的成员中移除对它们的引用；引用被删空的组同样补 DIRECT
+func pruneRegionGroups(groups []ProxyGroup, templates []GroupTemplate) []ProxyGroup {
+	regionOnly := map[string]bool{}
+	for _, gt := range templates {
+		only := true
+		for _, m := range gt.Members {
+			if !strings.HasPrefix(m, "!!REGION=") {
+				only = false
+				break
+			}
+		}
+		regionOnly[gt.Name] = only
+	}
+	empty := map[string]bool{}
+	for _, g := range groups {
+		if regionOnly[g.Name] && len(g.Proxies) == 1 && g.Proxies[0] == "DIRECT" {
+			empty[g.Name] = true
+		}
+	}
+	if len(empty) == 0 {
+		return groups
+	}
+	var out []ProxyGroup
+	for _, g := range groups {
+		if empty[g.Name] {
+			continue
+		}
+		var members []string
+		for _, p := range g.Proxies {
+			if !empty[p] {
+				members = append(members, p)
+			}
+		}
+		if len(members) == 0 {
+			members = []string{"DIRECT"}
+		}
+		g.Proxies = members
+		out = append(out, g)
+	}
+	return out
+}
+
+func renderConfig(cfg *ClashConfig) string {
+	var sb strings.Builder
+	for _, line := range cfg.Comments {
+		sb.WriteString("# " + line + "\n")
+	}
+
+	// --- 0. 如果是 Provider 模式，只输出 proxies 块 ---
+	if cfg.ProviderOnly {
+		sb.WriteString("proxies:\n")
+		for _, n := range cfg.Proxies {
+			writeNode(&sb, n)
+		}
+		return sb.String()
+	}
+
+	// --- 1. 基础头部 (Config模式) ---
+	g := cfg.General
+	if g == nil {
+		g = generalPresets["legacy"]()
+	}
+	writeGeneral(&sb, g)
+
+	// --- 2. 写入节点 (节点全部来自 proxy-providers 时省略) ---
+	if len(cfg.Proxies) > 0 || len(cfg.ProxyProviders) == 0 {
+		sb.WriteString("\nproxies:\n")
+		for _, n := range cfg.Proxies {
+			writeNode(&sb, n)
+		}
+	}
+
+	if len(cfg.ProxyProviders) > 0 {
+		sb.WriteString("\nproxy-providers:\n")
+		for _, p := range cfg.ProxyProviders {
+			writeProxyProvider(&sb, p, cfg.HealthCheck)
+		}
+	}
+
+	// --- 3. 策略组 ---
+	sb.WriteString("\nproxy-groups:\n")
+	for _, g := range cfg.Groups {
+		writeProxyGroup(&sb, g)
+	}
+
+	// --- 4. 规则 ---
+	if len(cfg.RuleProviders) > 0 {
+		sb.WriteString("\nrule-providers:\n")
+		for _, l := range cfg.RuleProviders {
+			writeRuleProvider(&sb, l, cfg.RuleProviderType, cfg.RuleProviderInterval)
+		}
+	}
+	sb.WriteString("\nrules:\n")
+	for _, r := range cfg.Rules {
+		sb.WriteString("  - " + r.String() + "\n")
+	}
+	return sb.String()
+}
+
+func writeProxyGroup(sb *strings.Builder, g ProxyGroup) {
+	sb.WriteString(fmt.Sprintf("  - name: %s\n    type: %s\n", g.Name, g.Type))
+	if g.Type != "select" && g.URL != "" {
+		sb.WriteString(fmt.Sprintf("    url: %s\n", g.URL))
+		if g.Interval > 0 {
+			sb.WriteString(fmt.Sprintf("    interval: %d\n", g.Interval))
+		}
+		if g.Timeout > 0 {
+			sb.WriteString(fmt.Sprintf("    timeout: %d\n", g.Timeout))
+		}
+		if g.Tolerance > 0 {
+			sb.WriteString(fmt.Sprintf("    tolerance: %d\n", g.Tolerance))
+		}
+		if g.Lazy != nil {
+			sb.WriteString(fmt.Sprintf("    lazy: %v\n", *g.Lazy))
+		}
+		if g.MaxFailedTimes > 0 {
+			sb.WriteString(fmt.Sprintf("    max-failed-times: %d\n", g.MaxFailedTimes))
+		}
+	}
+	if g.Type == "load-balance" && g.Strategy != "" {
+		sb.WriteString(fmt.Sprintf("    strategy: %s\n", g.Strategy))
+	}
+	if len(g.Use) > 0 {
+		sb.WriteString("    use:\n")
+		for _, u := range g.Use {
+			sb.WriteString(fmt.Sprintf("      - %s\n", yamlString(u)))
+		}
+		if g.Filter != "" {
+			sb.WriteString(fmt.Sprintf("    filter: %s\n", yamlString(g.Filter)))
+		}
+		if g.ExcludeFilter != "" {
+			sb.WriteString(fmt.Sprintf("    exclude-filter: %s\n", yamlString(g.ExcludeFilter)))
+		}
+		if len(g.Proxies) == 0 {
+			return
+		}
+	}
+	sb.WriteString("    proxies:\n")
+	for _, p := range g.Proxies {
+		sb.WriteString(fmt.Sprintf("      - %s\n", p))
+	}
+}
+
+// writeRuleProvider 输出一个 rule-provider，格式与行为取自规则源定义
+func writeRuleProvider(sb *strings.Builder, l RuleList, typ string, interval int) {
+	name := ruleProviderName(l.URL)
+	sb.WriteString(fmt.Sprintf("  %s:\n    type: %s\n    behavior: %s\n    format: %s\n", name, typ, l.Behavior, l.Format))
+	if typ == "http" {
+		sb.WriteString(fmt.Sprintf("    url: %s\n    interval: %d\n", l.URL, interval))
+	}
+	sb.WriteString(fmt.Sprintf("    path: %s\n", ruleProviderPath(l.URL)))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- Clash 外部控制器 ---
+
+// Controller 是 Clash/Mihomo 外部控制器 (external-controller) 的 RESTful API 客户端
+type Controller struct {
+	URL    string // 如 http://127.0.0.1:9090
+	Secret string
+
+	client *http.Client
+}
+
+// newController 接受 主机:端口 或完整 URL；监听 0.0.0.0/:: 时改连本机
+func newController(addr, secret string) *Controller {
+	addr = strings.TrimRight(strings.TrimSpace(addr), "/")
+	if !strings.Contains(addr, "://") {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if host == "" || host == "0.0.0.0" || host == "::" {
+				host = "127.0.0.1"
+			}
+			addr = net.JoinHostPort(host, port)
+		}
+		addr = "http://" + addr
+	}
+	return &Controller{URL: addr, Secret: secret, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// do 发送请求，body/out 为 nil 时不发送/不解析 JSON
+func (c *Controller) do(method, path string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.URL+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Secret)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("连接控制器失败: %s", shortNetError(err))
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("控制器拒绝访问，请检查 secret")
+	case resp.StatusCode >= 300:
+		// 出错时 Clash 返回 {"message": "..."}
+		var e struct{ Message string }
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, e.Message)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// Push 让 Clash 加载新配置 (PUT /configs)。控制器在本机时按路径加载，
+// 与 Clash 自己重启时读取的是同一个文件；远程控制器直接上传配置内容。
+// Mihomo 只允许按路径加载其工作目录内的文件，本机使用时输出文件应放在 Clash 配置目录下
+func (c *Controller) Push(path string, content string) error {
+	body := map[string]string{"payload": content}
+	if c.local() {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		body = map[string]string{"path": abs}
+	}
+	return c.do(http.MethodPut, "/configs?force=true", body, nil)
+}
+
+func (c *Controller) local() bool {
+	u, err := url.Parse(c.URL)
+	return err == nil && isLoopback(u.Hostname())
+}
+
+// controllerProxy 是 GET /proxies 返回的单个节点/策略组
+type controllerProxy struct {
+	Type string   `json:"type"`
+	All  []string `json:"all"`
+}
+
+func (c *Controller) Proxies() (map[string]controllerProxy, error) {
+	var resp struct {
+		Proxies map[string]controllerProxy `json:"proxies"`
+	}
+	if err := c.do(http.MethodGet, "/proxies", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Proxies, nil
+}
+
+// verifyLoaded 对照 GET /proxies 检查生成的节点和策略组是否都已加载，返回缺失项说明
+func (c *Controller) verifyLoaded(cfg *ClashConfig) ([]string, error) {
+	loaded, err := c.Proxies()
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, n := range cfg.Proxies {
+		if _, ok := loaded[n.Name]; !ok {
+			problems = append(problems, fmt.Sprintf("节点 %s 未加载", n.Name))
+		}
+	}
+	for _, g := range cfg.Groups {
+		p, ok := loaded[g.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("策略组 %s 未加载", g.Name))
+			continue
+		}
+		have := map[string]bool{}
+		for _, m := range p.All {
+			have[m] = true
+		}
+		var lost []string
+		for _, m := range g.Proxies {
+			if !have[m] {
+				lost = append(lost, m)
+			}
+		}
+		if len(lost) > 0 {
+			problems = append(problems, fmt.Sprintf("策略组 %s 缺少成员: %s", g.Name, strings.Join(lost, ", ")))
+		}
+	}
+	return problems, nil
+}
+
+// pushConfig 推送配置并核对加载结果，输出提示；Provider 模式的文件不是完整配置，不推送
+func pushConfig(c *Controller, path string, cfg *ClashConfig, content string) {
+	if cfg.ProviderOnly {
+		fmt.Println("ℹ️  Provider 模式只有节点列表，不推送到 Clash")
+		return
+	}
+	if err := c.Push(path, content); err != nil {
+		fmt.Printf("❌ 推送到 Clash 失败: %v\n", err)
+		return
+	}
+	problems, err := c.verifyLoaded(cfg)
+	if err != nil {
+		fmt.Printf("⚠️  配置已推送，但读取 /proxies 失败: %v\n", err)
+		return
+	}
+	if len(problems) == 0 {
+		fmt.Printf("🔄 Clash 已加载新配置：%d 个节点、%d 个策略组全部就绪\n", len(cfg.Proxies), len(cfg.Groups))
+		return
+	}
+	fmt.Printf("⚠️  Clash 已加载新配置，但有 %d 处与生成结果不一致：\n", len(problems))
+	for _, p := range problems {
+		fmt.Printf("   ❌ %s\n", p)
+	}
+}
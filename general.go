@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --- 基础设置 (端口 / DNS / TUN / 嗅探) ---
+
+// GeneralSettings 是 config.yaml 中 proxies 之前的全局设置
+type GeneralSettings struct {
+	Port        int
+	SocksPort   int
+	MixedPort   int
+	RedirPort   int
+	TProxyPort  int
+	AllowLan    bool
+	BindAddress string
+	Mode        string
+	LogLevel    string
+	IPv6        bool
+
+	ExternalController string
+	Secret             string
+
+	DNS     DNSSettings
+	TUN     TUNSettings
+	Sniffer bool
+}
+
+// DNSSettings 对应 dns: 块，Enable 为 false 时不输出
+type DNSSettings struct {
+	Enable            bool
+	Listen            string
+	EnhancedMode      string // fake-ip 或 redir-host
+	FakeIPRange       string
+	FakeIPFilter      []string
+	DefaultNameserver []string
+	Nameserver        []string
+	Fallback          []string
+	NameserverPolicy  []iniEntry // 域名/geosite -> DNS 服务器 (逗号分隔)，保持书写顺序
+
+	FallbackGeoIP     bool
+	FallbackGeoIPCode string
+	FallbackIPCIDR    []string
+	FallbackDomain    []string
+}
+
+// TUNSettings 对应 tun: 块，Enable 为 false 时不输出
+type TUNSettings struct {
+	Enable              bool
+	Stack               string // system / gvisor / mixed
+	AutoRoute           bool
+	AutoDetectInterface bool
+	DNSHijack           []string
+}
+
+// 预设：legacy 与旧版固定头部完全一致 (默认，升级后输出不变)；desktop 适合本机使用；
+// router 适合软路由/旁路由透明代理，控制器对局域网开放，必须设置 secret
+var generalPresets = map[string]func() *GeneralSettings{
+	"legacy": func() *GeneralSettings {
+		return &GeneralSettings{Port: 7890, SocksPort: 7891, AllowLan: true, Mode: "Rule", LogLevel: "info", ExternalController: "127.0.0.1:9090"}
+	},
+	"desktop": func() *GeneralSettings {
+		g := &GeneralSettings{MixedPort: 7890, Mode: "rule", LogLevel: "info", IPv6: true, ExternalController: "127.0.0.1:9090", Sniffer: true}
+		g.DNS = defaultDNS("127.0.0.1:1053")
+		return g
+	},
+	"router": func() *GeneralSettings {
+		g := &GeneralSettings{MixedPort: 7890, RedirPort: 7892, TProxyPort: 7893, AllowLan: true, BindAddress: "*",
+			Mode: "rule", LogLevel: "warning", IPv6: false, ExternalController: "0.0.0.0:9090", Sniffer: true}
+		g.DNS = defaultDNS("0.0.0.0:1053")
+		g.TUN = TUNSettings{Enable: true, Stack: "system", AutoRoute: true, AutoDetectInterface: true, DNSHijack: []string{"any:53"}}
+		return g
+	},
+}
+
+// defaultDNS 国内域名走国内 DoH，其余走境外 DoH，避免 DNS 泄露
+func defaultDNS(listen string) DNSSettings {
+	return DNSSettings{
+		Enable:            true,
+		Listen:            listen,
+		EnhancedMode:      "fake-ip",
+		FakeIPRange:       "198.18.0.1/16",
+		FakeIPFilter:      []string{"*.lan", "*.local", "+.msftconnecttest.com", "+.msftncsi.com", "time.*.com", "ntp.*.com", "+.stun.*.*"},
+		DefaultNameserver: []string{"223.5.5.5", "119.29.29.29"},
+		Nameserver:        []string{"https://dns.alidns.com/dns-query", "https://doh.pub/dns-query"},
+		Fallback:          []string{"https://1.1.1.1/dns-query", "https://dns.google/dns-query"},
+		NameserverPolicy: []iniEntry{
+			{Key: "geosite:cn", Value: "https://dns.alidns.com/dns-query,https://doh.pub/dns-query"},
+		},
+		FallbackGeoIP:     true,
+		FallbackGeoIPCode: "CN",
+		FallbackIPCIDR:    []string{"240.0.0.0/4"},
+	}
+}
+
+// buildGeneralSettings 以预设为基础，再应用 converter.ini [clash] 中的覆盖项
+func buildGeneralSettings(preset string, entries []iniEntry) (*GeneralSettings, error) {
+	sec := &iniSection{Name: "clash", Entries: entries}
+	if preset == "" {
+		preset = sec.Get("preset")
+	}
+	if preset == "" {
+		preset = "legacy"
+	}
+	mk, ok := generalPresets[strings.ToLower(preset)]
+	if !ok {
+		return nil, fmt.Errorf("未知的预设 %q (可选: desktop, router, legacy)", preset)
+	}
+	g := mk()
+
+	policyReset := false
+	for _, e := range entries {
+		var err error
+		v := e.Value
+		switch strings.ToLower(e.Key) {
+		case "preset":
+		case "port":
+			g.Port, err = strconv.Atoi(v)
+		case "socks_port":
+			g.SocksPort, err = strconv.Atoi(v)
+		case "mixed_port":
+			g.MixedPort, err = strconv.Atoi(v)
+		case "redir_port":
+			g.RedirPort, err = strconv.Atoi(v)
+		case "tproxy_port":
+			g.TProxyPort, err = strconv.Atoi(v)
+		case "allow_lan":
+			g.AllowLan, err = strconv.ParseBool(v)
+		case "bind_address":
+			g.BindAddress = v
+		case "mode":
+			g.Mode = v
+		case "log_level":
+			g.LogLevel = v
+		case "ipv6":
+			g.IPv6, err = strconv.ParseBool(v)
+		case "external_controller":
+			g.ExternalController = v
+		case "secret":
+			g.Secret = v
+		case "sniffer":
+			g.Sniffer, err = strconv.ParseBool(v)
+
+		case "dns":
+			g.DNS.Enable, err = strconv.ParseBool(v)
+			if g.DNS.Enable && g.DNS.EnhancedMode == "" {
+				g.DNS = defaultDNS("0.0.0.0:1053")
+			}
+		case "dns_listen":
+			g.DNS.Listen = v
+		case "dns_mode":
+			if v != "fake-ip" && v != "redir-host" {
+				err = fmt.Errorf("应为 fake-ip 或 redir-host")
+			}
+			g.DNS.EnhancedMode = v
+		case "fake_ip_range":
+			g.DNS.FakeIPRange = v
+		case "fake_ip_filter":
+			g.DNS.FakeIPFilter = splitList(v)
+		case "default_nameserver":
+			g.DNS.DefaultNameserver = splitList(v)
+		case "nameserver":
+			g.DNS.Nameserver = splitList(v)
+		case "fallback":
+			g.DNS.Fallback = splitList(v)
+		case "nameserver_policy":
+			// 第一次出现时替换预设，之后追加
+			idx := strings.Index(v, "=")
+			if idx <= 0 {
+				err = fmt.Errorf("应为 域名=DNS服务器[,DNS服务器...]")
+				break
+			}
+			if !policyReset {
+				g.DNS.NameserverPolicy = nil
+				policyReset = true
+			}
+			g.DNS.NameserverPolicy = append(g.DNS.NameserverPolicy, iniEntry{Key: strings.TrimSpace(v[:idx]), Value: strings.TrimSpace(v[idx+1:]), Line: e.Line})
+		case "fallback_filter_geoip":
+			g.DNS.FallbackGeoIP, err = strconv.ParseBool(v)
+		case "fallback_filter_geoip_code":
+			g.DNS.FallbackGeoIPCode = v
+		case "fallback_filter_ipcidr":
+			g.DNS.FallbackIPCIDR = splitList(v)
+		case "fallback_filter_domain":
+			g.DNS.FallbackDomain = splitList(v)
+
+		case "tun":
+			g.TUN.Enable, err = strconv.ParseBool(v)
+			if g.TUN.Enable && g.TUN.Stack == "" {
+				g.TUN = TUNSettings{Enable: true, Stack: "system", AutoRoute: true, AutoDetectInterface: true, DNSHijack: []string{"any:53"}}
+			}
+		case "tun_stack":
+			g.TUN.Stack = v
+		case "tun_auto_route":
+			g.TUN.AutoRoute, err = strconv.ParseBool(v)
+		case "tun_dns_hijack":
+			g.TUN.DNSHijack = splitList(v)
+		default:
+			err = fmt.Errorf("未知的设置项")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("[clash] 第 %d 行 %s: %v", e.Line, e.Key, err)
+		}
+	}
+	// TUN 模式必须由 Clash 接管 DNS，否则劫持到的 53 端口请求无人应答
+	if g.TUN.Enable && !g.DNS.Enable {
+		return nil, fmt.Errorf("[clash] 开启 tun 时必须同时开启 dns")
+	}
+	// 对外开放的控制器没有密码时，局域网内任何人都能切换节点、改写配置
+	if g.ExternalController != "" && g.Secret == "" {
+		if host, _, err := net.SplitHostPort(g.ExternalController); err == nil && !isLoopback(host) {
+			return nil, fmt.Errorf("[clash] external_controller=%s 对外开放，必须设置 secret", g.ExternalController)
+		}
+	}
+	return g, nil
+}
+
+// writeGeneral 输出全局设置、dns、tun、sniffer 块
+func writeGeneral(sb *strings.Builder, g *GeneralSettings) {
+	port := func(key string, v int) {
+		if v > 0 {
+			fmt.Fprintf(sb, "%s: %d\n", key, v)
+		}
+	}
+	port("port", g.Port)
+	port("socks-port", g.SocksPort)
+	port("mixed-port", g.MixedPort)
+	port("redir-port", g.RedirPort)
+	port("tproxy-port", g.TProxyPort)
+	fmt.Fprintf(sb, "allow-lan: %v\n", g.AllowLan)
+	if g.BindAddress != "" {
+		fmt.Fprintf(sb, "bind-address: %s\n", yamlString(g.BindAddress))
+	}
+	fmt.Fprintf(sb, "mode: %s\nlog-level: %s\n", g.Mode, g.LogLevel)
+	if g.IPv6 {
+		sb.WriteString("ipv6: true\n")
+	}
+	if g.ExternalController != "" {
+		fmt.Fprintf(sb, "external-controller: %s\n", g.ExternalController)
+	}
+	if g.Secret != "" {
+		fmt.Fprintf(sb, "secret: %s\n", yamlString(g.Secret))
+	}
+
+	if d := g.DNS; d.Enable {
+		sb.WriteString("\ndns:\n  enable: true\n")
+		if d.Listen != "" {
+			fmt.Fprintf(sb, "  listen: %s\n", d.Listen)
+		}
+		fmt.Fprintf(sb, "  ipv6: %v\n", g.IPv6)
+		fmt.Fprintf(sb, "  enhanced-mode: %s\n", d.EnhancedMode)
+		if d.EnhancedMode == "fake-ip" {
+			fmt.Fprintf(sb, "  fake-ip-range: %s\n", d.FakeIPRange)
+			writeYAMLList(sb, "  ", "fake-ip-filter", d.FakeIPFilter)
+		}
+		writeYAMLList(sb, "  ", "default-nameserver", d.DefaultNameserver)
+		writeYAMLList(sb, "  ", "nameserver", d.Nameserver)
+		writeYAMLList(sb, "  ", "fallback", d.Fallback)
+		if len(d.NameserverPolicy) > 0 {
+			sb.WriteString("  nameserver-policy:\n")
+			for _, p := range d.NameserverPolicy {
+				var servers []string
+				for _, s := range splitList(p.Value) {
+					servers = append(servers, yamlString(s))
+				}
+				fmt.Fprintf(sb, "    %s: [%s]\n", yamlString(p.Key), strings.Join(servers, ", "))
+			}
+		}
+		if len(d.Fallback) > 0 {
+			sb.WriteString("  fallback-filter:\n")
+			fmt.Fprintf(sb, "    geoip: %v\n", d.FallbackGeoIP)
+			if d.FallbackGeoIP && d.FallbackGeoIPCode != "" {
+				fmt.Fprintf(sb, "    geoip-code: %s\n", d.FallbackGeoIPCode)
+			}
+			writeYAMLList(sb, "    ", "ipcidr", d.FallbackIPCIDR)
+			writeYAMLList(sb, "    ", "domain", d.FallbackDomain)
+		}
+	}
+
+	if t := g.TUN; t.Enable {
+		sb.WriteString("\ntun:\n  enable: true\n")
+		fmt.Fprintf(sb, "  stack: %s\n", t.Stack)
+		fmt.Fprintf(sb, "  auto-route: %v\n", t.AutoRoute)
+		fmt.Fprintf(sb, "  auto-detect-interface: %v\n", t.AutoDetectInterface)
+		writeYAMLList(sb, "  ", "dns-hijack", t.DNSHijack)
+	}
+
+	if g.Sniffer {
+		sb.WriteString("\nsniffer:\n  enable: true\n  sniff:\n")
+		sb.WriteString("    HTTP:\n      ports: [80, 8080-8880]\n      override-destination: true\n")
+		sb.WriteString("    TLS:\n      ports: [443, 8443]\n")
+		sb.WriteString("    QUIC:\n      ports: [443, 8443]\n")
+	}
+}
+
+func writeYAMLList(sb *strings.Builder, indent, key string, vals []string) {
+	if len(vals) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "%s%s:\n", indent, key)
+	for _, v := range vals {
+		fmt.Fprintf(sb, "%s  - %s\n", indent, yamlString(v))
+	}
+}
+
+var yamlPlain = regexp.MustCompile(`^[A-Za-z0-9_./][A-Za-z0-9_./:@-]*$`)
+
+// yamlString 在值含有 YAML 特殊字符 (如 *、+.、空格) 时加单引号
+func yamlString(s string) string {
+	if yamlPlain.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildGeneralSettingsLegacy(t *testing.T) {
+	g, err := buildGeneralSettings("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	writeGeneral(&sb, g)
+	// 与旧版固定头部逐字节一致，升级后输出不变
+	want := "port: 7890\nsocks-port: 7891\nallow-lan: true\nmode: Rule\nlog-level: info\nexternal-controller: 127.0.0.1:9090\n"
+	if sb.String() != want {
+		t.Errorf("默认头部:\n%s\n应为:\n%s", sb.String(), want)
+	}
+}
+
+func TestBuildGeneralSettings(t *testing.T) {
+	entry := func(kv ...string) []iniEntry {
+		var out []iniEntry
+		for i := 0; i+1 < len(kv); i += 2 {
+			out = append(out, iniEntry{Key: kv[i], Value: kv[i+1], Line: i/2 + 1})
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		preset  string
+		entries []iniEntry
+		wantErr string
+		check   func(*GeneralSettings) bool
+	}{
+		{
+			name:    "预设写在 [clash] 中",
+			entries: entry("preset", "desktop"),
+			check:   func(g *GeneralSettings) bool { return g.MixedPort == 7890 && g.DNS.Enable && g.Sniffer },
+		},
+		{
+			name:    "覆盖端口和 DNS 模式",
+			preset:  "desktop",
+			entries: entry("mixed_port", "7999", "dns_mode", "redir-host", "ipv6", "false"),
+			check: func(g *GeneralSettings) bool {
+				return g.MixedPort == 7999 && g.DNS.EnhancedMode == "redir-host" && !g.IPv6
+			},
+		},
+		{
+			name:    "nameserver_policy 首次出现时替换预设",
+			preset:  "desktop",
+			entries: entry("nameserver_policy", "+.corp=10.0.0.1", "nameserver_policy", "geosite:cn=223.5.5.5"),
+			check: func(g *GeneralSettings) bool {
+				p := g.DNS.NameserverPolicy
+				return len(p) == 2 && p[0].Key == "+.corp" && p[1].Value == "223.5.5.5"
+			},
+		},
+		{
+			name:    "开启 dns 时补齐默认配置",
+			entries: entry("dns", "true", "tun", "true"),
+			check:   func(g *GeneralSettings) bool { return g.DNS.EnhancedMode == "fake-ip" && g.TUN.Stack == "system" },
+		},
+		{
+			name:    "命令行预设优先",
+			preset:  "legacy",
+			entries: entry("preset", "router"),
+			check:   func(g *GeneralSettings) bool { return g.Port == 7890 && !g.TUN.Enable },
+		},
+		{
+			name:    "未知预设",
+			preset:  "server",
+			wantErr: "未知的预设",
+		},
+		{
+			name:    "未知设置项",
+			entries: entry("mixed-port", "7890"),
+			wantErr: "未知的设置项",
+		},
+		{
+			name:    "端口不是数字",
+			entries: entry("port", "abc"),
+			wantErr: "第 1 行 port",
+		},
+		{
+			name:    "dns_mode 取值错误",
+			preset:  "desktop",
+			entries: entry("dns_mode", "fakeip"),
+			wantErr: "fake-ip 或 redir-host",
+		},
+		{
+			name:    "tun 没有 dns",
+			preset:  "router",
+			entries: entry("secret", "s3cret", "dns", "false"),
+			wantErr: "开启 tun 时必须同时开启 dns",
+		},
+		{
+			name:    "router 预设没有 secret",
+			preset:  "router",
+			wantErr: "必须设置 secret",
+		},
+		{
+			name:    "router 预设设置了 secret",
+			preset:  "router",
+			entries: entry("secret", "s3cret"),
+			check:   func(g *GeneralSettings) bool { return g.ExternalController == "0.0.0.0:9090" && g.Secret == "s3cret" },
+		},
+		{
+			name:    "控制器对局域网开放",
+			entries: entry("external_controller", "192.168.1.2:9090"),
+			wantErr: "必须设置 secret",
+		},
+		{
+			name:    "控制器只监听本机",
+			entries: entry("external_controller", "[::1]:9090"),
+			check:   func(g *GeneralSettings) bool { return g.Secret == "" },
+		},
+	}
+	for _, tt := range tests {
+		g, err := buildGeneralSettings(tt.preset, tt.entries)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: 错误 %v，应包含 %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !tt.check(g) {
+			t.Errorf("%s: 结果不符 %+v", tt.name, g)
+		}
+	}
+}
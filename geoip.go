@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- GeoIP (MaxMind MMDB) ---
+
+// 仅实现按 IP 查询国家代码所需的最小 MMDB 读取器，兼容 GeoLite2-Country / Country.mmdb
+type geoIP struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	treeSize   uint
+	dataStart  uint
+	ipv4Start  uint
+
+	PreferName bool // 节点名能识别出地区时以节点名为准，默认 GeoIP 优先
+}
+
+var mmdbMetaMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+func openGeoIP(path string) (*geoIP, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := bytes.LastIndex(buf, mmdbMetaMarker)
+	if idx < 0 {
+		return nil, errors.New("不是有效的 MMDB 文件")
+	}
+	metaStart := uint(idx + len(mmdbMetaMarker))
+	meta, _, err := (&mmdbDecoder{buf: buf[metaStart:]}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("读取 MMDB 元数据失败: %v", err)
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("MMDB 元数据格式错误")
+	}
+
+	g := &geoIP{
+		buf:        buf,
+		nodeCount:  uint(toUint(m["node_count"])),
+		recordSize: uint(toUint(m["record_size"])),
+		ipVersion:  uint(toUint(m["ip_version"])),
+	}
+	if g.recordSize != 24 && g.recordSize != 28 && g.recordSize != 32 {
+		return nil, fmt.Errorf("不支持的 record_size: %d", g.recordSize)
+	}
+	g.treeSize = g.recordSize * 2 / 8 * g.nodeCount
+	g.dataStart = g.treeSize + 16
+	if g.dataStart > metaStart {
+		return nil, errors.New("MMDB 文件已损坏")
+	}
+
+	// IPv6 库中 IPv4 地址位于 ::/96 之下，预先走完前 96 位
+	if g.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < g.nodeCount; i++ {
+			node = g.readRecord(node, 0)
+		}
+		g.ipv4Start = node
+	}
+	return g, nil
+}
+
+func (g *geoIP) readRecord(node, bit uint) uint {
+	switch g.recordSize {
+	case 24:
+		b := g.buf[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := g.buf[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(g.buf[node*8+bit*4:]))
+	}
+}
+
+// Country 返回 IP 所属国家的 ISO 代码，查不到时返回空字符串
+func (g *geoIP) Country(ip net.IP) string {
+	var addr []byte
+	node := uint(0)
+	if v4 := ip.To4(); v4 != nil {
+		addr = v4
+		if g.ipVersion == 6 {
+			node = g.ipv4Start
+		}
+	} else {
+		if g.ipVersion == 4 {
+			return ""
+		}
+		addr = ip.To16()
+	}
+
+	for i := 0; i < len(addr)*8 && node < g.nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-uint(i%8))) & 1
+		node = g.readRecord(node, bit)
+	}
+	if node <= g.nodeCount {
+		return ""
+	}
+	offset := node - g.nodeCount - 16
+	d := &mmdbDecoder{buf: g.buf[g.dataStart:]}
+	rec, _, err := d.decode(offset)
+	if err != nil {
+		return ""
+	}
+	m, _ := rec.(map[string]interface{})
+	for _, key := range []string{"country", "registered_country"} {
+		if c, ok := m[key].(map[string]interface{}); ok {
+			if code, ok := c["iso_code"].(string); ok && code != "" {
+				return strings.ToUpper(code)
+			}
+		}
+	}
+	return ""
+}
+
+type mmdbDecoder struct {
+	buf   []byte
+	depth int
+}
+
+var errMMDBBounds = errors.New("MMDB 数据越界")
+
+// 指针与嵌套 map/array 的最大深度，正常的国家库不超过 10 层，
+// 损坏的文件中指针可能成环
+const mmdbMaxDepth = 64
+
+// decode 解码 offset 处的一个值，返回值与下一个值的偏移
+func (d *mmdbDecoder) decode(offset uint) (interface{}, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errMMDBBounds
+	}
+	if d.depth >= mmdbMaxDepth {
+		return nil, 0, errors.New("MMDB 数据嵌套过深")
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == 1 { // 指针
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr)
+		return v, next, err
+	}
+	if typ == 0 { // 扩展类型
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errMMDBBounds
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, errMMDBBounds
+		}
+		v := uint(0)
+		for _, b := range d.buf[offset : offset+n] {
+			v = v<<8 | uint(b)
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + v
+		case 30:
+			size = 285 + v
+		default:
+			size = 65821 + v
+		}
+	}
+
+	switch typ {
+	case 7: // map
+		m := make(map[string]interface{}, d.capacity(size, offset))
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			v, next2, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			ks, _ := k.(string)
+			m[ks] = v
+			offset = next2
+		}
+		return m, offset, nil
+	case 11: // array
+		arr := make([]interface{}, 0, d.capacity(size, offset))
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, v)
+			offset = next
+		}
+		return arr, offset, nil
+	case 14: // boolean，值就是 size
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errMMDBBounds
+	}
+	raw := d.buf[offset : offset+size]
+	next := offset + size
+	switch typ {
+	case 2:
+		return string(raw), next, nil
+	case 3:
+		if size != 8 {
+			return nil, 0, errors.New("MMDB double 长度错误")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), next, nil
+	case 4, 10:
+		return raw, next, nil
+	case 5, 6, 9:
+		v := uint64(0)
+		for _, b := range raw {
+			v = v<<8 | uint64(b)
+		}
+		return v, next, nil
+	case 8:
+		v := int32(0)
+		for _, b := range raw {
+			v = v<<8 | int32(b)
+		}
+		return v, next, nil
+	case 15:
+		if size != 4 {
+			return nil, 0, errors.New("MMDB float 长度错误")
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(raw)), next, nil
+	}
+	return nil, 0, fmt.Errorf("不支持的 MMDB 数据类型: %d", typ)
+}
+
+// capacity 限制 map/array 的预分配大小：每个元素至少占 1 字节，
+// 不超过剩余数据，损坏的文件不会触发巨大的分配
+func (d *mmdbDecoder) capacity(size, offset uint) uint {
+	if rest := uint(len(d.buf)) - offset; size > rest {
+		return rest
+	}
+	return size
+}
+
+func (d *mmdbDecoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	ss := uint(ctrl>>3) & 0x3
+	vvv := uint(ctrl & 0x7)
+	n := ss + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errMMDBBounds
+	}
+	b := d.buf[offset : offset+n]
+	var ptr uint
+	switch ss {
+	case 0:
+		ptr = vvv<<8 | uint(b[0])
+	case 1:
+		ptr = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 2:
+		ptr = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		ptr = uint(binary.BigEndian.Uint32(b))
+	}
+	return ptr, offset + n, nil
+}
+
+func toUint(v interface{}) uint64 {
+	switch x := v.(type) {
+	case uint64:
+		return x
+	case int32:
+		return uint64(x)
+	}
+	return 0
+}
+
+// resolveRegions 解析节点服务器地址并用 GeoIP 库填充 Node.Region，
+// 查不到的节点保持原样，由 classifyNodes 退回按节点名识别。
+// PreferName 时只处理节点名识别不出地区的节点，适合入口 IP 在国内的中转节点
+func resolveRegions(nodes []Node, db *geoIP) (found int) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, 16)
+	for i := range nodes {
+		if nodes[i].Region == infoRegion || db.PreferName && !needsGeoIP(nodes[i]) {
+			continue
+		}
+		wg.Add(1)
+		go func(n *Node) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ip := net.ParseIP(n.Server)
+			if ip == nil {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				ips, err := net.DefaultResolver.LookupIP(ctx, "ip", n.Server)
+				cancel()
+				if err != nil || len(ips) == 0 {
+					return
+				}
+				ip = ips[0]
+			}
+			if code := db.Country(ip); code != "" {
+				if _, ok := regionTable[code]; ok {
+					n.Region = code
+					mu.Lock()
+					found++
+					mu.Unlock()
+				}
+			}
+		}(&nodes[i])
+	}
+	wg.Wait()
+	return found
+}
+
+// needsGeoIP 判断节点名是否无法识别地区 (geoip_priority=name 时才查 GeoIP)；
+// 带来源标签的节点在 labelNode 中已按原名识别，识别不出时记为 Other
+func needsGeoIP(n Node) bool {
+	return n.Region == "Other" || n.Region == "" && detectRegion(n.Name) == ""
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// mmdbString 按 MMDB 格式编码一个短字符串 (长度 < 29)
+func mmdbString(s string) []byte {
+	return append([]byte{0x40 | byte(len(s))}, s...)
+}
+
+func TestMMDBDecode(t *testing.T) {
+	// {"country": {"iso_code": "JP"}}，内层的 "JP" 通过指针引用偏移 0
+	country := []byte{0xE1}
+	country = append(country, mmdbString("country")...)
+	country = append(country, 0xE1)
+	country = append(country, mmdbString("iso_code")...)
+	country = append(country, 0x20, 0x00)
+	withPointer := append(mmdbString("JP"), country...)
+
+	tests := []struct {
+		name   string
+		buf    []byte
+		offset uint
+		want   interface{}
+		next   uint
+		err    bool
+	}{
+		{"字符串", mmdbString("HK"), 0, "HK", 3, false},
+		{"uint16", []byte{0xA2, 0x01, 0x02}, 0, uint64(0x0102), 3, false},
+		{"uint32 零长度", []byte{0xC0}, 0, uint64(0), 1, false},
+		{"int32 (扩展类型)", []byte{0x01, 0x01, 0x7F}, 0, int32(0x7F), 3, false},
+		{"布尔 (扩展类型)", []byte{0x01, 0x07}, 0, true, 2, false},
+		{"数组 (扩展类型)", append([]byte{0x02, 0x04}, append(mmdbString("a"), mmdbString("b")...)...), 0, []interface{}{"a", "b"}, 6, false},
+		{"指针与嵌套 map", withPointer, 3, map[string]interface{}{"country": map[string]interface{}{"iso_code": "JP"}}, uint(len(withPointer)), false},
+		{"长度超过 28 的字符串", append([]byte{0x5D, 0x01}, make([]byte, 30)...), 0, string(make([]byte, 30)), 32, false},
+		{"偏移越界", mmdbString("HK"), 5, nil, 0, true},
+		{"字符串被截断", []byte{0x45, 'a', 'b'}, 0, nil, 0, true},
+		{"指针被截断", []byte{0x28, 0x00}, 0, nil, 0, true},
+		{"指针成环", []byte{0x20, 0x00}, 0, nil, 0, true},
+		{"map 键值被截断", []byte{0xE1, 0x41, 'k'}, 0, nil, 0, true},
+		{"double 长度错误", []byte{0x64, 0, 0, 0, 0}, 0, nil, 0, true},
+		{"map 声明超大长度", []byte{0xFF, 0xFF, 0xFF, 0xFF}, 0, nil, 0, true},
+		{"数组声明超大长度", []byte{0x1F, 0x04, 0xFF, 0xFF, 0xFF}, 0, nil, 0, true},
+	}
+	for _, tt := range tests {
+		d := &mmdbDecoder{buf: tt.buf}
+		got, next, err := d.decode(tt.offset)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: 应返回错误，得到 %#v", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || next != tt.next {
+			t.Errorf("%s: 得到 %#v (下一个 %d)，应为 %#v (下一个 %d)", tt.name, got, next, tt.want, tt.next)
+		}
+		if d.depth != 0 {
+			t.Errorf("%s: 解码结束后深度应归零，得到 %d", tt.name, d.depth)
+		}
+	}
+}
+
+func TestNeedsGeoIP(t *testing.T) {
+	tests := []struct {
+		node Node
+		want bool
+	}{
+		{Node{Name: "香港 01"}, false},
+		{Node{Name: "1.2.3.4"}, true},
+		{Node{Name: "机场A | 香港 01", Region: "HK", Source: "机场A"}, false},
+		{Node{Name: "机场A | 节点 1", Region: "Other", Source: "机场A"}, true},
+		{Node{Name: "ℹ️ 剩余流量", Region: infoRegion}, false},
+	}
+	for _, tt := range tests {
+		if got := needsGeoIP(tt.node); got != tt.want {
+			t.Errorf("needsGeoIP(%q, Region=%q) = %v，应为 %v", tt.node.Name, tt.node.Region, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// --- 输入整理 ---
+
+// 聊天软件、网页和 Windows 记事本复制时常夹带的不可见字符
+var invisibleChars = strings.NewReplacer(
+	"\ufeff", "", // BOM
+	"\u200b", "", // 零宽空格
+	"\u200c", "",
+	"\u200d", "",
+	"\u2060", "",
+	"\u00ad", "", // 软连字符
+)
+
+// cleanLine 去掉不可见字符和首尾空白 (含 Windows 换行留下的 \r)
+func cleanLine(s string) string {
+	return strings.TrimSpace(invisibleChars.Replace(s))
+}
+
+// inputLines 统一 \r\n、\r 换行后按行拆分，行号与原文一致
+func inputLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Split(text, "\n")
+}
+
+// 分享链接的协议前缀，含能识别但暂不支持的协议；
+// 前一个字符不能是字母数字，避免从 vless:// 中拆出 ss://
+var linkScheme = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])((?:vless|vmess|ssr|ss|trojan|hysteria2|hysteria|hy2|tuic|wireguard|wg|socks5|socks|anytls|snell)://)`)
+
+// splitLinks 把粘在同一行的多条链接拆开 (空格、逗号分隔或首尾相连)；
+// 链接前的多余文字单独成为一项由调用方报告，"1." 这类编号直接去掉
+func splitLinks(line string) []string {
+	locs := linkScheme.FindAllStringSubmatchIndex(line, -1)
+	if len(locs) == 0 || len(locs) == 1 && locs[0][2] == 0 {
+		return []string{line}
+	}
+	var parts []string
+	add := func(s string) {
+		if s = strings.Trim(s, " \t,;|"); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if prefix := line[:locs[0][2]]; listNumber.MatchString(prefix) {
+		line = line[len(prefix):]
+		for _, loc := range locs {
+			loc[2] -= len(prefix)
+		}
+	}
+	start := 0
+	for _, loc := range locs {
+		add(line[start:loc[2]])
+		start = loc[2]
+	}
+	add(line[start:])
+	return parts
+}
+
+var listNumber = regexp.MustCompile(`^[\s\d.)、:：-]*$`)
+
+var base64Line = regexp.MustCompile(`^[A-Za-z0-9+/_-]+=*$`)
+
+// inputLine 是整理后的一条待解析内容及其所在行号
+type inputLine struct {
+	No   int
+	Text string
+}
+
+// linkInput 逐行整理粘贴或读取的内容：拆开同一行的多条链接，
+// 连续的 base64 行先暂存，遇到其他内容或结束时整体解码为链接
+type linkInput struct {
+	blob     []string
+	blobLine int
+}
+
+// isBlob 粗略判断一行是否是 base64 内容：单独出现时至少 16 个字符，
+// 折行的最后一段可能很短
+func (in *linkInput) isBlob(line string) bool {
+	return base64Line.MatchString(line) && (len(line) >= 16 || len(in.blob) > 0)
+}
+
+// feed 接收一行原始输入，返回可以解析的内容；空行和 # 注释跳过
+func (in *linkInput) feed(no int, line string) []inputLine {
+	line = cleanLine(line)
+	if in.isBlob(line) {
+		if len(in.blob) == 0 {
+			in.blobLine = no
+		}
+		in.blob = append(in.blob, line)
+		return nil
+	}
+	out := in.flush()
+	if line == "" || strings.HasPrefix(line, "#") {
+		return out
+	}
+	for _, p := range splitLinks(line) {
+		out = append(out, inputLine{no, p})
+	}
+	return out
+}
+
+// flush 解码暂存的 base64 内容，解码出的链接都记为 base64 开始的行号；
+// 解码失败时原样返回各行，由调用方报告无法识别
+func (in *linkInput) flush() []inputLine {
+	if len(in.blob) == 0 {
+		return nil
+	}
+	var out []inputLine
+	decoded, err := decodeBase64(strings.Join(in.blob, ""))
+	if err == nil && strings.Contains(decoded, "://") {
+		for _, line := range inputLines(decoded) {
+			if line = cleanLine(line); line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			for _, p := range splitLinks(line) {
+				out = append(out, inputLine{in.blobLine, p})
+			}
+		}
+	} else {
+		for i, line := range in.blob {
+			out = append(out, inputLine{in.blobLine + i, line})
+		}
+	}
+	in.blob = nil
+	return out
+}
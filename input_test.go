@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestSplitLinks(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"vless://a@h:1#x", []string{"vless://a@h:1#x"}},
+		{"vless://a@h:1#x ss://b@h:2#y", []string{"vless://a@h:1#x", "ss://b@h:2#y"}},
+		{"vless://a@h:1#x,hy2://p@h:3#z", []string{"vless://a@h:1#x", "hy2://p@h:3#z"}},
+		{"vless://a@h:1#日本ss://b@h:2#y", []string{"vless://a@h:1#日本", "ss://b@h:2#y"}},
+		// 紧挨英文字母时无法区分名称结尾和协议前缀 (vless:// 本身以 ss:// 结尾)，不拆开
+		{"vless://a@h:1#xss://b@h:2#y", []string{"vless://a@h:1#xss://b@h:2#y"}},
+		{"vless://a@h:1#x | vmess://abc", []string{"vless://a@h:1#x", "vmess://abc"}},
+		{"1. vless://a@h:1#x", []string{"vless://a@h:1#x"}},
+		{"节点：vless://a@h:1#x", []string{"节点：", "vless://a@h:1#x"}},
+		{"hello there", []string{"hello there"}},
+	}
+	for _, tt := range tests {
+		if got := splitLinks(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitLinks(%q) = %q，应为 %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestLinkInputFeed(t *testing.T) {
+	blob := base64.StdEncoding.EncodeToString([]byte("vless://a@h:1#x\r\nss://b@h:2#y\n"))
+	tests := []struct {
+		name  string
+		lines []string
+		want  []inputLine
+	}{
+		{
+			name:  "不可见字符与 Windows 换行",
+			lines: []string{"\ufeff\u200bvless://a@h:1#x\r", "  hy2://p@h:3#z\u200d  "},
+			want:  []inputLine{{1, "vless://a@h:1#x"}, {2, "hy2://p@h:3#z"}},
+		},
+		{
+			name:  "空行和注释跳过",
+			lines: []string{"", "# 我的节点", "vless://a@h:1#x"},
+			want:  []inputLine{{3, "vless://a@h:1#x"}},
+		},
+		{
+			name:  "同一行多条链接",
+			lines: []string{"vless://a@h:1#x ss://b@h:2#y"},
+			want:  []inputLine{{1, "vless://a@h:1#x"}, {1, "ss://b@h:2#y"}},
+		},
+		{
+			name:  "整段 base64",
+			lines: []string{"vless://c@h:4#w", blob},
+			want:  []inputLine{{1, "vless://c@h:4#w"}, {2, "vless://a@h:1#x"}, {2, "ss://b@h:2#y"}},
+		},
+		{
+			name:  "折行的 base64，最后一段很短",
+			lines: []string{blob[:16], blob[16:32], blob[32:], "", "hy2://p@h:3#z"},
+			want:  []inputLine{{1, "vless://a@h:1#x"}, {1, "ss://b@h:2#y"}, {5, "hy2://p@h:3#z"}},
+		},
+		{
+			name:  "解码失败的 base64 原样返回",
+			lines: []string{"abcdefghijklmnopqrstuvwxyz012345"},
+			want:  []inputLine{{1, "abcdefghijklmnopqrstuvwxyz012345"}},
+		},
+		{
+			name:  "短单词不当作 base64",
+			lines: []string{"hello", "vless://a@h:1#x"},
+			want:  []inputLine{{1, "hello"}, {2, "vless://a@h:1#x"}},
+		},
+	}
+	for _, tt := range tests {
+		var in linkInput
+		var got []inputLine
+		for i, line := range tt.lines {
+			got = append(got, in.feed(i+1, line)...)
+		}
+		got = append(got, in.flush()...)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: 得到 %v，应为 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLinksReportsUnknown(t *testing.T) {
+	results := parseLinks("vmess://abc\r\nhello there\r\n# 注释\r\n")
+	if len(results) != 2 {
+		t.Fatalf("得到 %d 条结果，应为 2: %+v", len(results), results)
+	}
+	for i, want := range []struct {
+		line  int
+		proto string
+	}{{1, "VMESS"}, {2, "?"}} {
+		r := results[i]
+		if r.Line != want.line || r.Proto != want.proto || r.OK() {
+			t.Errorf("第 %d 条: 行 %d 协议 %q 错误 %v，应为 行 %d 协议 %q 且被拒绝", i+1, r.Line, r.Proto, r.Err, want.line, want.proto)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- 链接诊断 ---
+
+// LinkResult 是一行分享链接的解析结果：Err 非空时节点不可用 (拒绝)，
+// 只有 Warnings 时节点照常使用但可能连不上 (降级)
+type LinkResult struct {
+	Line     int
+	Proto    string
+	Node     Node
+	Err      error
+	Warnings []string
+}
+
+func (r LinkResult) OK() bool { return r.Err == nil }
+
+// Status 返回 接受/降级/拒绝
+func (r LinkResult) Status() string {
+	switch {
+	case r.Err != nil:
+		return "❌ 拒绝"
+	case len(r.Warnings) > 0:
+		return "⚠️ 降级"
+	}
+	return "✅ 接受"
+}
+
+// Detail 返回节点名及问题说明
+func (r LinkResult) Detail() string {
+	if r.Err != nil {
+		if r.Node.Name != "" {
+			return r.Node.Name + ": " + r.Err.Error()
+		}
+		return r.Err.Error()
+	}
+	if len(r.Warnings) > 0 {
+		return r.Node.Name + ": " + strings.Join(r.Warnings, "；")
+	}
+	return r.Node.Name
+}
+
+// 各协议已写入配置或可以安全忽略的参数，其余参数会提示被忽略
+var linkParams = map[string]map[string]bool{
+	"VLESS": {"security": true, "sni": true, "pbk": true, "sid": true, "fp": true, "type": true, "encryption": true, "headerType": true, "spx": true, "flow": true},
+	"Hy2":   {"sni": true, "insecure": true},
+	"SS":    {},
+}
+
+// Clash/Mihomo 支持的 SS 加密方式
+var ssCiphers = map[string]bool{
+	"aes-128-gcm": true, "aes-192-gcm": true, "aes-256-gcm": true,
+	"aes-128-cfb": true, "aes-192-cfb": true, "aes-256-cfb": true,
+	"aes-128-ctr": true, "aes-192-ctr": true, "aes-256-ctr": true,
+	"chacha20-ietf-poly1305": true, "xchacha20-ietf-poly1305": true,
+	"chacha20-ietf": true, "xchacha20": true, "rc4-md5": true,
+	"2022-blake3-aes-128-gcm": true, "2022-blake3-aes-256-gcm": true, "2022-blake3-chacha20-poly1305": true,
+	"none": true,
+}
+
+// checkLink 解析一行链接并检查结果；不是分享链接的内容记为拒绝
+func checkLink(lineNo int, line string) LinkResult {
+	node, proto, err := parseLink(line)
+	r := LinkResult{Line: lineNo, Proto: proto, Node: node, Err: err}
+	if err == errUnknownLink {
+		r.Proto, r.Err = "?", fmt.Errorf("无法识别的内容 %q", shorten(line, 40))
+	}
+	if r.Err != nil {
+		return r
+	}
+	warn := func(format string, args ...interface{}) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+	}
+	fail := func(format string, args ...interface{}) {
+		if r.Err == nil {
+			r.Err = fmt.Errorf(format, args...)
+		}
+	}
+
+	u, _ := url.Parse(line)
+	var q url.Values
+	if u != nil {
+		q = u.Query()
+		if u.Fragment == "" {
+			warn("缺少名称，使用 %q", node.Name)
+		}
+	}
+
+	// 协议特有的问题优先报告，通用的必填字段检查放在最后
+	switch proto {
+	case "VLESS":
+		// 生成的 VLESS 节点固定为 Reality + TCP
+		if s := q.Get("security"); s != "" && s != "reality" {
+			fail("暂不支持 security=%s，只支持 Reality", s)
+		}
+		if t := q.Get("type"); t != "" && t != "tcp" {
+			fail("暂不支持传输方式 type=%s，只支持 tcp", t)
+		}
+		if node.PublicKey == "" {
+			fail("缺少 Reality 公钥 (pbk)")
+		}
+		if node.ServerName == "" {
+			warn("缺少 sni")
+		}
+		if node.ClientFingerprint == "" {
+			warn("缺少 fp，客户端指纹为空")
+		}
+		if f := q.Get("flow"); f != "" {
+			warn("flow=%s 未写入配置，服务端要求时将无法连接", f)
+		}
+	case "Hy2":
+		if node.ServerName == "" {
+			warn("缺少 sni")
+		}
+	case "SS":
+		if node.Password == "" && node.Cipher != "" && !ssCiphers[strings.ToLower(node.Cipher)] {
+			fail("用户信息既不是 base64 编码的 method:password，也不是明文 method:password")
+		} else if node.Cipher != "" && !ssCiphers[strings.ToLower(node.Cipher)] {
+			warn("未知的加密方式 %q", node.Cipher)
+		}
+	}
+	validateNode(node, fail)
+
+	var ignored []string
+	for k := range q {
+		if !linkParams[proto][k] {
+			ignored = append(ignored, k)
+		}
+	}
+	if len(ignored) > 0 {
+		sort.Strings(ignored)
+		warn("忽略不支持的参数 %s", strings.Join(ignored, ", "))
+	}
+	return r
+}
+
+// linkSummary 统计接受、降级、拒绝的数量
+func linkSummary(results []LinkResult) (ok, degraded, rejected int) {
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			rejected++
+		case len(r.Warnings) > 0:
+			degraded++
+		default:
+			ok++
+		}
+	}
+	return
+}
+
+// printLinkTable 输出链接解析结果表；onlyProblems 为 true 时只列出降级和拒绝的行
+func printLinkTable(title string, results []LinkResult, onlyProblems bool) {
+	var rows []LinkResult
+	for _, r := range results {
+		if !onlyProblems || !r.OK() || len(r.Warnings) > 0 {
+			rows = append(rows, r)
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Printf("\n📋 %s\n", title)
+	fmt.Printf("   %s %s %s %s\n", padRight("行", 5), padRight("协议", 6), padRight("状态", 8), "节点 / 问题")
+	for _, r := range rows {
+		fmt.Printf("   %s %s %s %s\n", padRight(strconv.Itoa(r.Line), 5), padRight(r.Proto, 6), padRight(r.Status(), 8), r.Detail())
+	}
+}
+
+// padRight 按终端显示宽度补空格，中文和 emoji 占两列
+func padRight(s string, width int) string {
+	w := 0
+	for _, c := range s {
+		switch {
+		case c == 0xFE0F || c == 0x200D:
+		case c >= 0x1100:
+			w += 2
+		default:
+			w++
+		}
+	}
+	if w >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-w)
+}
+
+// shorten 截断过长的内容用于提示
+func shorten(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max]) + "…"
+	}
+	return s
+}
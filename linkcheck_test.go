@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckLink(t *testing.T) {
+	const reality = "vless://uuid@1.2.3.4:443?security=reality&sni=www.apple.com&pbk=KEY&sid=ab&fp=chrome&type=tcp"
+	tests := []struct {
+		name   string
+		line   string
+		status string
+		proto  string
+		want   string // 拒绝原因或降级提示中应包含的内容
+	}{
+		{"VLESS Reality", reality + "#jp", "✅ 接受", "VLESS", ""},
+		{"Hy2", "hy2://pass@1.2.3.4:443?sni=a.example.com&insecure=1#hk", "✅ 接受", "Hy2", ""},
+		{"SS base64 用户信息", "ss://YWVzLTEyOC1nY206cGFzcw==@1.2.3.4:8388#sg", "✅ 接受", "SS", ""},
+		{"SS 明文用户信息", "ss://aes-256-gcm:pass@1.2.3.4:8388#sg", "✅ 接受", "SS", ""},
+
+		{"缺少名称", reality, "⚠️ 降级", "VLESS", "缺少名称，使用 \"vless\""},
+		{"VLESS 缺少 sni 和 fp", "vless://uuid@1.2.3.4:443?security=reality&pbk=KEY#jp", "⚠️ 降级", "VLESS", "缺少 sni；缺少 fp"},
+		{"flow 未写入配置", reality + "&flow=xtls-rprx-vision#jp", "⚠️ 降级", "VLESS", "flow=xtls-rprx-vision 未写入配置"},
+		{"忽略的参数", reality + "&alpn=h2&allowInsecure=1#jp", "⚠️ 降级", "VLESS", "忽略不支持的参数 allowInsecure, alpn"},
+		{"Hy2 忽略 obfs", "hy2://pass@1.2.3.4:443?sni=a.example.com&obfs=salamander#hk", "⚠️ 降级", "Hy2", "忽略不支持的参数 obfs"},
+		{"SS 未知加密方式", "ss://Zm9vLTI1NjpwYXNz@1.2.3.4:8388#sg", "⚠️ 降级", "SS", "未知的加密方式 \"foo-256\""},
+
+		{"VLESS 缺少 pbk", "vless://uuid@1.2.3.4:443?security=reality&sni=a&fp=chrome#jp", "❌ 拒绝", "VLESS", "缺少 Reality 公钥 (pbk)"},
+		{"VLESS security=tls", "vless://uuid@1.2.3.4:443?security=tls&sni=a#jp", "❌ 拒绝", "VLESS", "暂不支持 security=tls"},
+		{"VLESS ws 传输", "vless://uuid@1.2.3.4:443?security=reality&sni=a&pbk=KEY&fp=chrome&type=ws#jp", "❌ 拒绝", "VLESS", "暂不支持传输方式 type=ws"},
+		{"VLESS 缺少 UUID", "vless://1.2.3.4:443?security=reality&pbk=KEY&sni=a&fp=chrome#jp", "❌ 拒绝", "VLESS", "缺少 UUID"},
+		{"端口无效", "hy2://pass@1.2.3.4:0?sni=a#hk", "❌ 拒绝", "Hy2", "端口 \"0\" 无效"},
+		{"SS 用户信息无法解析", "ss://Z2FyYmFnZQ@1.2.3.4:8388#sg", "❌ 拒绝", "SS", "既不是 base64 编码的 method:password"},
+		{"不支持的协议", "trojan://pass@1.2.3.4:443#us", "❌ 拒绝", "TROJAN", "暂不支持 trojan:// 链接"},
+		{"无法识别的内容", "这不是链接", "❌ 拒绝", "?", "无法识别的内容 \"这不是链接\""},
+	}
+	for _, tt := range tests {
+		r := checkLink(7, tt.line)
+		if r.Line != 7 || r.Status() != tt.status || r.Proto != tt.proto {
+			t.Errorf("%s: 第 %d 行 %s %s (%s)，应为 %s %s", tt.name, r.Line, r.Proto, r.Status(), r.Detail(), tt.proto, tt.status)
+			continue
+		}
+		if tt.want != "" && !strings.Contains(r.Detail(), tt.want) {
+			t.Errorf("%s: 说明 %q 应包含 %q", tt.name, r.Detail(), tt.want)
+		}
+	}
+}
+
+func TestLinkSummary(t *testing.T) {
+	results := parseLinks("vless://uuid@1.2.3.4:443?security=reality&sni=a&pbk=KEY&fp=chrome#jp\nhy2://pass@1.2.3.4:443#hk\n\n# 注释\nfoo\n")
+	if ok, degraded, rejected := linkSummary(results); ok != 1 || degraded != 1 || rejected != 1 {
+		t.Errorf("接受 %d 降级 %d 拒绝 %d，应各为 1", ok, degraded, rejected)
+	}
+	if n := acceptedNodes(results); len(n) != 2 || n[1].Name != "hk" {
+		t.Errorf("可用节点 %+v", n)
+	}
+}
+
+func TestPadRight(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"SS", 6, "SS    "},
+		{"协议", 6, "协议  "},
+		{"✅ 接受", 8, "✅ 接受 "},
+		{"⚠️ 降级", 8, "⚠️ 降级 "},
+		{"VLESS 节点", 4, "VLESS 节点"},
+	}
+	for _, tt := range tests {
+		if got := padRight(tt.s, tt.width); got != tt.want {
+			t.Errorf("padRight(%q, %d) = %q，应为 %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
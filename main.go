@@ -3,16 +3,15 @@ package main
 import (
 	"bufio"
 	"encoding/base64"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 )
 
 // --- 数据结构 ---
@@ -30,20 +29,28 @@ type Node struct {
 	ShortID           string // Reality ShortID
 	ClientFingerprint string // fp
 	SkipCertVerify    bool   // insecure
+	Region            string // 地区代码 (GeoIP 识别结果，可为空)
+	Source            string // 来源标签 (多来源合并时)
 }
 
 // 模式配置参数
 type ModeConfig struct {
-	Name            string
-	IsProvider      bool   // ★ 0号：ShellClash专用 (只输出节点)
-	IsMini          bool   
-	IsFull          bool   
-	IsNoReject      bool   
-	UseAdblockPlus  bool   
-	AutoGroupType   string 
-	UseCountryGroup bool   
-	TargetNetflix   string 
-	TargetGoogle    string 
+	Name       string
+	IsProvider bool          // ★ 0号：ShellClash专用 (只输出节点)
+	Template   *ModeTemplate // 策略组与规则绑定
+
+	RuleProviderType     string           // 为空时内联全部规则；http/file 时输出 rule-providers + RULE-SET
+	RuleProviderInterval int              // rule-providers 的更新间隔 (秒)
+	RuleSource           *RuleSource      // 规则源，为 nil 时使用 ACL4SSR
+	CustomRulesAfter     bool             // 自定义规则放在规则列表之后 (默认在最前面)
+	ExtraGroups          []GroupTemplate  // converter.ini [groups] 中的自定义策略组
+	Services             []*Service       // 开启的可选服务分流 (AI、Disney+、Spotify 等)
+	General              *GeneralSettings // 端口、DNS、TUN 等全局设置
+	HealthCheck          HealthCheck      // 测速组健康检查参数的统一覆盖
+	UserInfo             string           // 订阅流量信息写入方式：comment (默认), node, both, off
+	SourceGroups         bool             // 为每个带标签的来源生成一个选择组
+	ProxyProviders       []ProxyProvider  // 非空时输出 proxy-providers，策略组用 use + filter 引用节点
+	Comments             []string         // 写在文件开头的注释
 }
 
 // 规则源 (ACL4SSR)
@@ -63,6 +70,9 @@ const (
 	UrlSteamCN      = "https://raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/Ruleset/SteamCN.list"
 	UrlGames        = "https://raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/ProxyGFWlist.list"
 	UrlOneDrive     = "https://raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/OneDrive.list"
+	UrlDisney       = "https://raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/Ruleset/DisneyPlus.list"
+	UrlYouTube      = "https://raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/Ruleset/YouTube.list"
+	UrlGoogleAll    = "https://raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/Ruleset/Google.list"
 )
 
 func main() {
@@ -74,67 +84,277 @@ func main() {
 		}
 	}()
 
+	configFile := flag.String("config", "converter.ini", "配置文件路径 (地区别名等)")
+	geoipFile := flag.String("geoip", "", "GeoIP 国家数据库 (MMDB，如 GeoLite2-Country.mmdb)，用于按服务器 IP 识别地区")
+	geoipPriority := flag.String("geoip-priority", "", "地区识别优先级：ip (默认，GeoIP 优先) 或 name (节点名能识别时以节点名为准，适合中转节点)")
+	offline := flag.Bool("offline", false, "完全离线生成：只使用本地缓存和内置规则快照")
+	cacheDir := flag.String("cache-dir", "", "规则缓存目录 (默认 rule_cache)")
+	cacheMaxAge := flag.Duration("cache-max-age", 0, "规则缓存有效期，期内不重新下载 (默认 12h)")
+	mirrors := flag.String("mirror", "", "规则下载镜像，逗号分隔按顺序尝试 (direct, jsdelivr, ghproxy 类前缀, 含 {path} 的本地地址)")
+	providerType := flag.String("rule-providers", "", "规则输出方式：留空内联全部规则，http/file 输出 rule-providers")
+	providerInterval := flag.Int("rule-provider-interval", 0, "http 类型 rule-providers 的更新间隔秒数 (默认 86400)")
+	templateDir := flag.String("templates", "", "自定义模式模板目录 (subconverter 兼容的 .ini)，同名文件覆盖内置模式")
+	ruleSource := flag.String("rule-source", "", "规则源：acl4ssr (默认), loyalsoldier, blackmatrix7, dustinwin")
+	customPos := flag.String("custom-rules", "", "自定义规则位置：before (默认，优先于规则列表) 或 after (规则列表之后、兜底规则之前)")
+	preset := flag.String("preset", "", "全局设置预设：legacy (默认，旧版固定头部), desktop (本机使用), router (软路由透明代理，含 TUN，需设置 secret)")
+	testURL := flag.String("test-url", "", "测速组的测速地址：gstatic (默认), cloudflare, apple, cn (国内可直连) 或完整 URL")
+	serviceList := flag.String("services", "", "开启可选服务分流，逗号分隔：ai, disney, spotify, tiktok, bilibili, games 或 all")
+	userInfo := flag.String("userinfo", "", "订阅流量信息写入方式：comment (文件开头注释，默认), node (占位节点), both, off")
+	var sourceSpecs stringList
+	flag.Var(&sourceSpecs, "source", "带标签的节点来源 标签=订阅地址/文件/链接，可重复，节点名加 \"标签 | \" 前缀 (替代 [sources])")
+	var providerSpecs stringList
+	flag.Var(&providerSpecs, "proxy-provider", "完整配置改用 proxy-providers 引用节点：[名称=]订阅地址或文件路径，可重复 (替代 [general] proxy_provider)")
+	sourceGroups := flag.Bool("source-groups", false, "为每个来源生成一个选择组，放在 ♻️ 自动选择 之后")
+	watch := flag.Bool("watch", false, "守护模式：定时或在本地来源/规则文件变化时重新生成配置，内容变化才写入")
+	watchMode := flag.String("mode", "", "守护模式使用的模式：序号、模板名或文件名 (默认同菜单默认项)")
+	output := flag.String("output", "", "输出文件 (默认 config.yaml)")
+	interval := flag.Duration("interval", 0, "守护模式重新生成的间隔 (默认 6h)")
+	rulesFile := flag.String("rules-file", "", "守护模式的自定义规则文件，每行一条")
+	reload := flag.Bool("reload", false, "守护模式更新配置后推送到 Clash 并核对加载结果")
+	backups := flag.Int("backups", 0, "覆盖输出文件前保留的备份数量 (默认 5，0 不备份)")
+	assumeYes := flag.Bool("y", false, "覆盖现有输出文件前不再询问")
+	push := flag.Bool("push", false, "生成后通过 Clash 控制器 (PUT /configs) 加载新配置，并用 /proxies 核对节点和策略组")
+	controllerAddr := flag.String("controller", "", "Clash 控制器地址 (默认取 [controller] url 或 [clash] external_controller)")
+	controllerSecret := flag.String("secret", "", "Clash 控制器的 secret (默认取 [controller] secret 或 [clash] secret)")
+	serve := flag.Bool("serve", false, "以订阅转换服务运行：GET /sub?url=订阅地址&mode=6&token=令牌")
+	listenAddr := flag.String("listen", "", "订阅转换服务的监听地址 (默认 127.0.0.1:25500)")
+	serveToken := flag.String("token", "", "订阅转换服务的访问令牌，监听非本机地址时必须设置")
+	snapshotDir := flag.String("update-snapshot", "", "维护者用：把规则源 (默认 ACL4SSR，可配合 -rule-source) 的全部列表下载到指定目录 (通常为 snapshot)，重新编译后成为内置快照")
+	flag.Parse()
+
+	// 维护者用：下载全部规则到 snapshot 目录后重新编译即可更新内置快照
+	if isFlagSet("update-snapshot") {
+		if *snapshotDir == "" { *snapshotDir = "snapshot" }
+		src, err := findRuleSource(*ruleSource)
+		if err == nil {
+			err = updateSnapshot(*snapshotDir, src)
+		}
+		if err != nil {
+			fmt.Printf("❌ 更新快照失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	outputFile := "config.yaml"
+	if *output != "" { outputFile = *output }
 	var nodes []Node
+	var subs []*Subscription
 	
 	scanner := bufio.NewScanner(os.Stdin)
 
+	settings, err := loadSettings(*configFile, !isFlagSet("config"))
+	if err == nil {
+		err = applyRegionSettings(settings.Regions)
+	}
+	if err != nil {
+		fmt.Printf("❌ 读取配置文件失败: %v\n", err)
+		pause(scanner)
+		return
+	}
+
+	// 与模式无关的生成选项：命令行优先，其次配置文件
+	base, err := baseModeConfig(settings, cliOptions{
+		RuleSource: *ruleSource, ProviderType: *providerType, ProviderInterval: *providerInterval,
+		CustomPos: *customPos, Services: *serviceList, Preset: *preset, TestURL: *testURL, UserInfo: *userInfo,
+		SourceGroups: *sourceGroups, ProxyProviders: providerSpecs,
+	})
+	if err != nil {
+		fmt.Printf("❌ 读取全局设置失败: %v\n", err)
+		pause(scanner)
+		return
+	}
+
+	sources := settings.Sources
+	if len(sourceSpecs) > 0 {
+		sources = nil
+		for _, v := range sourceSpecs {
+			src, ok := parseSourceLine(v)
+			if !ok || src.Label == "" {
+				fmt.Printf("❌ -source 格式应为 标签=订阅地址/文件/链接: %s\n", v)
+				pause(scanner)
+				return
+			}
+			sources = append(sources, src)
+		}
+	}
+
+	if !isFlagSet("backups") { *backups = settings.Backups }
+	if *cacheDir == "" { *cacheDir = settings.CacheDir }
+	if *cacheMaxAge == 0 { *cacheMaxAge = settings.CacheMaxAge }
+	fetcher := NewRuleFetcher(*cacheDir, *cacheMaxAge, *offline || settings.Offline)
+	if *mirrors != "" {
+		fetcher.Mirrors = splitList(*mirrors)
+	} else if len(settings.Mirrors) > 0 {
+		fetcher.Mirrors = settings.Mirrors
+	}
+
+	if *geoipFile == "" { *geoipFile = settings.GeoIP }
+	var db *geoIP
+	if *geoipFile != "" {
+		if db, err = openGeoIP(*geoipFile); err != nil {
+			fmt.Printf("⚠️  GeoIP 数据库不可用，改为按节点名识别地区: %v\n", err)
+		} else {
+			switch *geoipPriority {
+			case "":
+				db.PreferName = settings.GeoIPName
+			case "ip", "name":
+				db.PreferName = *geoipPriority == "name"
+			default:
+				fmt.Println("❌ -geoip-priority 应为 ip 或 name")
+				os.Exit(1)
+			}
+		}
+	}
+
+	if *templateDir == "" { *templateDir = settings.Templates }
+	modes, err := loadModeTemplates(*templateDir)
+	if err != nil {
+		fmt.Printf("❌ 读取模式模板失败: %v\n", err)
+		pause(scanner)
+		return
+	}
+
+	// 订阅转换服务
+	if *serve {
+		if *listenAddr == "" { *listenAddr = settings.ServeListen }
+		if *serveToken == "" { *serveToken = settings.ServeToken }
+		srv := &Server{Modes: modes, Base: base, Fetcher: fetcher, GeoIP: db, Token: *serveToken, Sources: sources}
+		if err := srv.ListenAndServe(*listenAddr); err != nil {
+			fmt.Printf("❌ 订阅转换服务启动失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Clash 控制器：命令行优先，其次 [controller]，最后取生成配置中的 external-controller
+	var ctrl *Controller
+	if *controllerAddr == "" { *controllerAddr = settings.Controller }
+	if *controllerAddr == "" { *controllerAddr = base.General.ExternalController }
+	if *controllerSecret == "" { *controllerSecret = settings.ControllerSecret }
+	if *controllerSecret == "" { *controllerSecret = base.General.Secret }
+	if *controllerAddr != "" { ctrl = newController(*controllerAddr, *controllerSecret) }
+
+	// 守护模式
+	if *watch {
+		if !isFlagSet("output") && settings.WatchOutput != "" { outputFile = settings.WatchOutput }
+		if *watchMode == "" { *watchMode = settings.WatchMode }
+		if *interval == 0 { *interval = settings.WatchInterval }
+		if *rulesFile == "" { *rulesFile = settings.WatchRulesFile }
+		idx, err := findMode(modes, *watchMode)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		w := &Watcher{
+			Sources: sources, Mode: getModeConfig(modes, idx, base), RulesFile: *rulesFile,
+			Output: outputFile, Interval: *interval, Fetcher: fetcher, GeoIP: db, Backups: *backups,
+		}
+		if *reload || settings.WatchReload {
+			if w.Controller = ctrl; ctrl == nil {
+				fmt.Println("❌ 推送配置需要 [controller] url 或 [clash] external_controller")
+				os.Exit(1)
+			}
+		}
+		if err := w.Run(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Println("=============================================================================")
 	fmt.Println("          SS/VLESS/Hy2 转 Clash (v1.2 终极版)")
 	fmt.Println("=============================================================================")
 	
 	// --- 1. 读取链接 ---
-	fmt.Println(">>> 步骤1: 请粘贴链接 (支持 ss:// vless:// hy2://)")
-	fmt.Println("    (支持多行，粘贴完毕后输入 ok 并回车)")
+	fmt.Println(">>> 步骤1: 请粘贴链接 (支持 ss:// vless:// hy2://，也可粘贴订阅地址 http(s):// 或文件路径)")
+	fmt.Println("    (支持多行，标签=地址 可给来源加标签，粘贴完毕后输入 ok 并回车)")
 	fmt.Println("-----------------------------------------------------------------------------")
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.ToLower(line) == "ok" || strings.ToLower(line) == "done" {
-			break
+	// 配置文件/命令行中的来源先读取，仍可继续粘贴
+	var pasted, all []LinkResult
+	for _, src := range sources {
+		if res := readSource(src); res != nil {
+			nodes = append(nodes, res.Nodes...)
+			all = append(all, res.Results...)
+			if res.Sub != nil { subs = append(subs, res.Sub) }
 		}
-		if line == "" { continue }
-		
-		// 自动识别协议
-		if strings.HasPrefix(line, "vless://") {
-			node, err := parseVless(line)
-			if err != nil {
-				fmt.Printf(" [VLESS错误] %v\n", err)
-			} else {
-				nodes = append(nodes, node)
-				fmt.Printf(" [VLESS] %s\n", node.Name)
+	}
+
+	// 自动识别协议，无法识别的行也逐条报告
+	check := func(lines []inputLine) {
+		for _, l := range lines {
+			r := checkLink(l.No, l.Text)
+			pasted = append(pasted, r)
+			switch {
+			case r.Err != nil:
+				fmt.Printf(" [%s错误] 第 %d 行: %v\n", r.Proto, l.No, r.Err)
+			case len(r.Warnings) > 0:
+				nodes = append(nodes, r.Node)
+				fmt.Printf(" [%s] %s ⚠️  %s\n", r.Proto, r.Node.Name, strings.Join(r.Warnings, "；"))
+			default:
+				nodes = append(nodes, r.Node)
+				fmt.Printf(" [%s] %s\n", r.Proto, r.Node.Name)
 			}
-		} else if strings.HasPrefix(line, "hy2://") || strings.HasPrefix(line, "hysteria2://") {
-			node, err := parseHy2(line)
-			if err != nil {
-				fmt.Printf(" [Hy2错误] %v\n", err)
-			} else {
-				nodes = append(nodes, node)
-				fmt.Printf(" [Hy2] %s\n", node.Name)
+		}
+	}
+	var in linkInput
+	lineNo := 0
+paste:
+	for scanner.Scan() {
+		// 整段粘贴时只有 \r 换行的内容会读成一行，先拆开
+		for _, raw := range inputLines(scanner.Text()) {
+			lineNo++
+			line := cleanLine(raw)
+			if strings.ToLower(line) == "ok" || strings.ToLower(line) == "done" {
+				break paste
 			}
-		} else if strings.HasPrefix(line, "ss://") {
-			node, err := parseSS(line)
-			if err != nil {
-				fmt.Printf(" [SS错误] %v\n", err)
-			} else {
-				nodes = append(nodes, node)
-				fmt.Printf(" [SS] %s\n", node.Name)
+
+			// 订阅地址、文件或带标签的来源：读取后按行解析
+			if src, ok := parseSourceLine(line); ok && !in.isBlob(line) {
+				check(in.flush())
+				if res := readSource(src); res != nil {
+					nodes = append(nodes, res.Nodes...)
+					all = append(all, res.Results...)
+					if res.Sub != nil { subs = append(subs, res.Sub) }
+				}
+				continue
 			}
+			check(in.feed(lineNo, line))
 		}
 	}
+	check(in.flush())
+	// 全部通过时不再重复列表，有问题时列出粘贴的每一行
+	all = append(all, pasted...)
+	if ok, degraded, rejected := linkSummary(all); degraded+rejected > 0 {
+		printLinkTable("粘贴的链接：", pasted, false)
+		fmt.Printf("   合计：接受 %d，降级 %d，拒绝 %d\n", ok, degraded, rejected)
+	}
 
-	if len(nodes) == 0 {
+	nodes, removed, renamed := dedupeNodes(nodes)
+	if removed > 0 || renamed > 0 {
+		fmt.Printf("🧹 去掉 %d 个重复节点，%d 个重名节点已加序号\n", removed, renamed)
+	}
+	if len(nodes) == 0 && len(base.ProxyProviders) == 0 {
 		fmt.Println("❌ 未检测到有效节点，请重启。")
 		pause(scanner)
 		return
 	}
 
+	// GeoIP 地区识别 (可选)
+	if db != nil {
+		found := resolveRegions(nodes, db)
+		fmt.Printf("🌐 GeoIP 识别出 %d/%d 个节点的地区\n", found, len(nodes))
+	}
+
 	// --- 2. 读取自定义规则 ---
 	customRules := readCustomRules(scanner)
 
 	// --- 3. 选择模式 ---
-	modeIndex := showMenu(scanner)
-	config := getModeConfig(modeIndex)
+	modeIndex := showMenu(scanner, modes)
+	config := getModeConfig(modes, modeIndex, base)
+	nodes = applyUserInfo(&config, nodes, subs)
 	
 	fmt.Printf("\n🚀 正在生成 [%s] ...\n", config.Name)
 	
@@ -143,7 +363,12 @@ func main() {
 		fmt.Println("👉 请将生成的文件导入 ShellClash，然后在菜单里选择【规则模板】(如 DustinWin)。")
 	} else {
 		// 复杂模式
-		if customRules != "" {
+		if len(config.ProxyProviders) > 0 {
+			fmt.Printf("ℹ️  proxy-providers 模式：节点由 Clash 从 %d 个节点集自行更新，策略组按名称过滤。\n", len(config.ProxyProviders))
+		}
+		if config.RuleProviderType == "http" {
+			fmt.Println("ℹ️  rule-providers 模式：规则由 Clash 按 URL 自行下载更新。")
+		} else if len(customRules) > 0 && !config.CustomRulesAfter {
 			fmt.Println("ℹ️  检测到自定义规则，将智能剔除 ACL4SSR 在线规则的重复项...")
 		} else {
 			fmt.Println("⏳ 正在并发下载 ACL4SSR 规则库...")
@@ -151,228 +376,248 @@ func main() {
 	}
 
 	// --- 4. 生成内容 ---
-	content := generateYaml(nodes, config, customRules)
+	cfg := buildConfig(nodes, config, customRules, fetcher)
+	if issues := validateConfig(cfg); len(issues) > 0 {
+		fmt.Println("\n🔍 配置校验：")
+		if n := printIssues(issues); n > 0 {
+			fmt.Printf("❌ 生成的配置有 %d 个错误，未写入 %s\n", n, outputFile)
+			pause(scanner)
+			return
+		}
+	}
+	content := renderConfig(cfg)
 
 	// --- 5. 写入文件 ---
-	err := os.WriteFile(outputFile, []byte(content), 0644)
+	// 先展示与现有文件的差异，确认后再备份并原子替换，避免误粘贴毁掉可用的配置
+	if !confirmReplace(scanner, outputFile, content, *assumeYes) {
+		fmt.Printf("ℹ️  已取消，%s 保持不变\n", outputFile)
+		pause(scanner)
+		return
+	}
+	backup, err := replaceOutput(outputFile, []byte(content), *backups)
 	if err != nil {
 		fmt.Printf("❌ 写入失败: %v\n", err)
 	} else {
 		fmt.Println("=============================================================================")
 		fmt.Printf("✅ 成功！已生成文件: %s\n", outputFile)
+		if backup != "" { fmt.Printf("💾 原文件已备份为: %s\n", backup) }
+		if config.RuleProviderType == "file" && !config.IsProvider {
+			n, err := fetcher.saveRuleSets(filepath.Dir(outputFile))
+			if err != nil {
+				fmt.Printf("❌ 写入 ruleset 目录失败: %v\n", err)
+			} else {
+				fmt.Printf("✅ 已写入 %d 个规则文件到 ruleset/ (请与 %s 一起放到 Clash 配置目录)\n", n, outputFile)
+			}
+		}
 		if config.IsProvider {
 			fmt.Println("★ 文件类型：Provider (仅节点，供 ShellClash 在线规则使用)")
 		} else {
 			fmt.Println("★ 文件类型：ACL4SSR 完整配置 (含分流规则)")
 		}
 		fmt.Println("=============================================================================")
+		if *push || settings.Push {
+			if ctrl == nil {
+				fmt.Println("❌ 推送配置需要 [controller] url 或 [clash] external_controller")
+			} else {
+				pushConfig(ctrl, outputFile, cfg, content)
+			}
+		}
 	}
 	
 	pause(scanner)
 }
 
-func readCustomRules(scanner *bufio.Scanner) string {
-	fmt.Println("\n>>> 步骤2: 请粘贴自定义规则")
+// readSource 读取一个来源并输出结果，失败时返回 nil
+func readSource(src Source) *SourceResult {
+	res, err := loadSource(src)
+	if err != nil {
+		fmt.Printf(" [来源错误] %s: %v\n", src.Title(), err)
+		return nil
+	}
+	kind := "文件"
+	if res.Sub != nil {
+		kind = "订阅"
+	} else if strings.Contains(src.Spec, "://") {
+		kind = "链接"
+	}
+	fmt.Printf(" [%s] %s: %d 个节点", kind, src.Title(), len(res.Nodes))
+	if _, degraded, rejected := linkSummary(res.Results); degraded+rejected > 0 {
+		fmt.Printf("，%d 个降级，%d 个拒绝", degraded, rejected)
+	}
+	fmt.Println()
+	printLinkTable(src.Title()+" 中有问题的链接：", res.Results, true)
+	if res.Sub != nil && res.Sub.Info != nil {
+		fmt.Printf("        📊 %s\n", res.Sub.Info.Summary())
+	}
+	return res
+}
+
+// confirmReplace 输出文件已存在且内容不同时显示差异摘要并询问是否覆盖
+func confirmReplace(scanner *bufio.Scanner, path, content string, yes bool) bool {
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return true
+	}
+	diff := diffSummary(string(old), content)
+	if diff == nil {
+		fmt.Printf("ℹ️  %s 内容没有变化\n", path)
+		return true
+	}
+	fmt.Printf("\n📝 与现有 %s 相比：\n", path)
+	for _, line := range diff {
+		fmt.Println("   " + line)
+	}
+	if yes {
+		return true
+	}
+	fmt.Printf("👉 确认覆盖 %s？(Y/n): ", path)
+	if !scanner.Scan() {
+		return true
+	}
+	ans := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	return ans == "" || ans == "y" || ans == "yes"
+}
+
+func readCustomRules(scanner *bufio.Scanner) []CustomRule {
+	fmt.Println("\n>>> 步骤2: 请粘贴自定义规则 (如 DOMAIN-SUFFIX,example.com,🚀 节点选择，可带 \"- \" 前缀)")
 	fmt.Println("    (如果是模式 0，此步骤会被忽略，直接输 ok)")
 	fmt.Println("    -----------------------------------------------------------------------------")
 
-	var sb strings.Builder
+	var lines []string
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if strings.ToLower(line) == "ok" || strings.ToLower(line) == "done" { break }
-		if line == "" { continue }
-		sb.WriteString("  " + line + "\n")
+		lines = append(lines, line)
+	}
+	rules, errs := parseCustomRules(lines)
+	for _, err := range errs {
+		fmt.Printf("   ❌ %v\n", err)
 	}
-	return sb.String()
+	if len(errs) > 0 {
+		fmt.Printf("⚠️  %d 行自定义规则有误，已忽略\n", len(errs))
+	}
+	if len(rules) > 0 {
+		fmt.Printf("✅ 读取到 %d 条自定义规则\n", len(rules))
+	}
+	return rules
 }
 
-func showMenu(scanner *bufio.Scanner) int {
+func showMenu(scanner *bufio.Scanner, modes []*ModeTemplate) int {
+	def := defaultModeIndex(modes)
 	fmt.Println("\n>>> 步骤3: 请选择模式:")
 	fmt.Println("-----------------------------------------------------------------------------")
-	fmt.Println(" [0]  ★ ShellClash 专用源 (Provider) - 推荐")
-	fmt.Println("      说明：只生成 proxies 列表，给 ShellClash 导入后配合 DustinWin 规则使用。")
-	fmt.Println("-----------------------------------------------------------------------------")
-	fmt.Println(" [1]  ACL4SSR_Online 默认版")
-	fmt.Println(" [2]  ACL4SSR_Online_AdblockPlus 更多去广告")
-	fmt.Println(" [3]  ACL4SSR_Online_MultiCountry 多国分组")
-	fmt.Println(" [4]  ACL4SSR_Online_NoAuto 无自动测速")
-	fmt.Println(" [5]  ACL4SSR_Online_NoReject 无广告拦截")
-	fmt.Println(" [6]  ACL4SSR_Online_Mini 精简版 (★默认)")
-	fmt.Println(" [7]  ACL4SSR_Online_Mini_AdblockPlus 精简版+更多去广告")
-	fmt.Println(" [8]  ACL4SSR_Online_Mini_NoAuto 精简版+无自动测速")
-	fmt.Println(" [9]  ACL4SSR_Online_Mini_Fallback 精简版+故障转移")
-	fmt.Println(" [10] ACL4SSR_Online_Mini_MultiMode 精简版+多模式")
-	fmt.Println(" [11] ACL4SSR_Online_Mini_MultiCountry 精简版+多国分组")
-	fmt.Println(" [12] ACL4SSR_Online_Full 全分组")
-	fmt.Println(" [13] ACL4SSR_Online_Full_MultiMode 全分组+多模式")
-	fmt.Println(" [14] ACL4SSR_Online_Full_NoAuto 全分组+无自动测速")
-	fmt.Println(" [15] ACL4SSR_Online_Full_AdblockPlus 全分组+更多去广告")
-	fmt.Println(" [16] ACL4SSR_Online_Full_Netflix 全分组+奈飞加强")
-	fmt.Println(" [17] ACL4SSR_Online_Full_Google 全分组+谷歌细分")
+	for i, m := range modes {
+		fmt.Printf(" [%d]%s %s\n", i, strings.Repeat(" ", 2-len(strconv.Itoa(i))), m.Desc)
+		if m.ProviderOnly {
+			fmt.Println("      说明：只生成 proxies 列表，给 ShellClash 导入后配合 DustinWin 规则使用。")
+			fmt.Println("-----------------------------------------------------------------------------")
+		}
+	}
 	fmt.Println("-----------------------------------------------------------------------------")
-	fmt.Print("👉 请输入数字 (直接回车默认选 6): ")
+	fmt.Printf("👉 请输入数字 (直接回车默认选 %d): ", def)
 
 	if scanner.Scan() {
 		text := strings.TrimSpace(scanner.Text())
-		if text == "" { return 6 }
+		if text == "" { return def }
 		val, err := strconv.Atoi(text)
-		if err == nil { return 6 }
-		if val >= 0 && val <= 17 { return val }
-		return 6
+		if err != nil { return def }
+		if val >= 0 && val < len(modes) { return val }
+		return def
 	}
-	return 6
+	return def
 }
 
-func getModeConfig(mode int) ModeConfig {
-	c := ModeConfig{AutoGroupType: "url-test", TargetNetflix: "🎥 奈飞视频", TargetGoogle: "📢 谷歌服务"}
-	switch mode {
-	case 0:
-		c.Name = "ShellClash Provider (纯节点)"
-		c.IsProvider = true // ★ 仅输出 proxies
-	case 1: c.Name = "ACL4SSR_Online 默认版"
-	case 2: c.Name = "ACL4SSR_Online_AdblockPlus"; c.UseAdblockPlus = true
-	case 3: c.Name = "ACL4SSR_Online_MultiCountry"; c.UseCountryGroup = true
-	case 4: c.Name = "ACL4SSR_Online_NoAuto"; c.AutoGroupType = "select"
-	case 5: c.Name = "ACL4SSR_Online_NoReject"; c.IsNoReject = true
-	case 6: c.Name = "ACL4SSR_Online_Mini"; c.IsMini = true
-	case 7: c.Name = "ACL4SSR_Online_Mini_AdblockPlus"; c.IsMini = true; c.UseAdblockPlus = true
-	case 8: c.Name = "ACL4SSR_Online_Mini_NoAuto"; c.IsMini = true; c.AutoGroupType = "select"
-	case 9: c.Name = "ACL4SSR_Online_Mini_Fallback"; c.IsMini = true; c.AutoGroupType = "fallback"
-	case 10: c.Name = "ACL4SSR_Online_Mini_MultiMode"; c.IsMini = true; c.AutoGroupType = "all"
-	case 11: c.Name = "ACL4SSR_Online_Mini_MultiCountry"; c.IsMini = true; c.UseCountryGroup = true
-	case 12: c.Name = "ACL4SSR_Online_Full"; c.IsFull = true
-	case 13: c.Name = "ACL4SSR_Online_Full_MultiMode"; c.IsFull = true; c.AutoGroupType = "all"
-	case 14: c.Name = "ACL4SSR_Online_Full_NoAuto"; c.IsFull = true; c.AutoGroupType = "select"
-	case 15: c.Name = "ACL4SSR_Online_Full_AdblockPlus"; c.IsFull = true; c.UseAdblockPlus = true
-	case 16: c.Name = "ACL4SSR_Online_Full_Netflix"; c.IsFull = true
-	case 17: c.Name = "ACL4SSR_Online_Full_Google"; c.IsFull = true
-	default:
-		c.Name = "ACL4SSR_Online_Mini"
-		c.IsMini = true
-	}
-	if c.IsMini {
-		c.TargetNetflix = "🚀 节点选择"
-		c.TargetGoogle = "🚀 节点选择"
+func getModeConfig(modes []*ModeTemplate, mode int, base ModeConfig) ModeConfig {
+	if mode < 0 || mode >= len(modes) {
+		mode = defaultModeIndex(modes)
 	}
+	t := modes[mode]
+	c := base
+	c.Name, c.IsProvider, c.Template = t.Name, t.ProviderOnly, t
 	return c
 }
 
-func generateYaml(nodes []Node, c ModeConfig, customRules string) string {
-	var sb strings.Builder
-
-	// --- 0. 如果是 Provider 模式，只输出 proxies 块 ---
-	if c.IsProvider {
-		sb.WriteString("proxies:\n")
-		for _, n := range nodes {
-			writeNode(&sb, n)
-		}
-		return sb.String()
-	}
-
-	// --- 1. 基础头部 (Config模式) ---
-	sb.WriteString("port: 7890\nsocks-port: 7891\nallow-lan: true\nmode: Rule\nlog-level: info\nexternal-controller: 127.0.0.1:9090\n")
+// cliOptions 是与模式无关的命令行参数，为空时取配置文件中的值
+type cliOptions struct {
+	RuleSource       string
+	ProviderType     string
+	ProviderInterval int
+	CustomPos        string
+	Services         string
+	Preset           string
+	TestURL          string
+	UserInfo         string
+	SourceGroups     bool
+	ProxyProviders   []string
+}
 
-	// --- 2. 写入节点 ---
-	sb.WriteString("\nproxies:\n")
-	for _, n := range nodes {
-		writeNode(&sb, n)
+// baseModeConfig 合并命令行与配置文件中与模式无关的选项，交互模式与 serve 模式共用；
+// 可以回退到默认值的错误只打印提示
+func baseModeConfig(settings *Settings, o cliOptions) (ModeConfig, error) {
+	var c ModeConfig
+	c.ExtraGroups = settings.Groups
+	c.HealthCheck = settings.HealthCheck
+	c.SourceGroups = o.SourceGroups || settings.SourceGroups
+	if o.TestURL != "" { c.HealthCheck.URL = resolveTestURL(o.TestURL) }
+
+	var err error
+	if c.General, err = buildGeneralSettings(o.Preset, settings.Clash); err != nil {
+		return c, err
 	}
-
-	// --- 4. 复杂 ACL4SSR 模式逻辑 ---
-	countryGroups := map[string][]string{}
-	if c.UseCountryGroup {
-		countryGroups = classifyNodes(nodes)
+	if len(o.ProxyProviders) == 0 { o.ProxyProviders = settings.ProxyProviders }
+	names := map[string]bool{}
+	for _, v := range o.ProxyProviders {
+		p, err := parseProxyProvider(v)
+		if err == nil && names[p.Name] {
+			err = fmt.Errorf("名称 %q 重复", p.Name)
+		}
+		if err != nil {
+			return c, fmt.Errorf("proxy-provider %s: %v", v, err)
+		}
+		names[p.Name] = true
+		c.ProxyProviders = append(c.ProxyProviders, p)
 	}
-
-	sb.WriteString("\nproxy-groups:\n")
-	sb.WriteString("  - name: 🚀 节点选择\n    type: select\n    proxies:\n")
-	if c.AutoGroupType == "all" {
-		sb.WriteString("      - ♻️ 自动选择\n      - 🔯 故障转移\n      - ⚖️ 负载均衡\n")
+	if o.RuleSource == "" { o.RuleSource = settings.RuleSource }
+	if src, err := findRuleSource(o.RuleSource); err != nil {
+		fmt.Printf("⚠️  %v，改用 ACL4SSR\n", err)
 	} else {
-		sb.WriteString("      - ♻️ 自动选择\n")
-	}
-	
-	if c.UseCountryGroup {
-		for _, name := range []string{"HK", "TW", "JP", "SG", "US", "Other"} {
-			if len(countryGroups[name]) > 0 {
-				sb.WriteString(fmt.Sprintf("      - %s\n", getCountryGroupName(name)))
-			}
-		}
+		c.RuleSource = src
 	}
-	for _, n := range nodes { sb.WriteString(fmt.Sprintf("      - %s\n", n.Name)) }
-
-	if c.AutoGroupType == "all" {
-		writeAutoGroup(&sb, "♻️ 自动选择", "url-test", nodes)
-		writeAutoGroup(&sb, "🔯 故障转移", "fallback", nodes)
-		writeAutoGroup(&sb, "⚖️ 负载均衡", "load-balance", nodes)
+	if o.Services == "" { o.Services = settings.Services }
+	if svcs, err := findServices(splitList(o.Services)); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
 	} else {
-		writeAutoGroup(&sb, "♻️ 自动选择", c.AutoGroupType, nodes)
-	}
-
-	if !c.IsMini {
-		writeProxyGroup(&sb, "📲 电报消息", "select")
-		writeProxyGroup(&sb, "📹 油管视频", "select")
-		writeProxyGroup(&sb, "🎥 奈飞视频", "select")
-		writeProxyGroup(&sb, "🌍 国外媒体", "select")
-		writeProxyGroup(&sb, "Ⓜ️ 微软服务", "select")
-		writeProxyGroup(&sb, "📢 谷歌服务", "select")
-		writeProxyGroup(&sb, "🍎 苹果服务", "select")
-		if c.IsFull {
-			writeProxyGroup(&sb, "🎮 游戏服务", "select")
-			writeProxyGroup(&sb, "☁️ 微软云盘", "select")
-			writeProxyGroup(&sb, "🚂 Steam", "select")
-		}
+		c.Services = svcs
 	}
-	if !c.IsNoReject {
-		sb.WriteString("  - name: 🛑 广告拦截\n    type: select\n    proxies:\n      - REJECT\n      - DIRECT\n")
+	if o.CustomPos == "" { o.CustomPos = settings.CustomRulePosition }
+	switch o.CustomPos {
+	case "", "before":
+	case "after":
+		c.CustomRulesAfter = true
+	default:
+		fmt.Printf("⚠️  未知的自定义规则位置 %q，改为 before\n", o.CustomPos)
 	}
-	sb.WriteString("  - name: 🎯 全球直连\n    type: select\n    proxies:\n      - DIRECT\n      - 🚀 节点选择\n")
-	sb.WriteString("  - name: 🐟 漏网之鱼\n    type: select\n    proxies:\n      - 🚀 节点选择\n      - DIRECT\n")
-
-	sb.WriteString("\nrules:\n")
-	
-	// 智能去重逻辑
-	exclusionMap := make(map[string]bool)
-	if customRules != "" {
-		sb.WriteString(customRules)
-		lines := strings.Split(customRules, "\n")
-		for _, line := range lines {
-			parts := strings.Split(line, ",")
-			if len(parts) >= 2 {
-				exclusionMap[strings.TrimSpace(strings.ToLower(parts[1]))] = true
-			}
-		}
+	if o.UserInfo == "" { o.UserInfo = settings.UserInfo }
+	switch o.UserInfo {
+	case "", "comment":
+		c.UserInfo = "comment"
+	case "node", "both", "off":
+		c.UserInfo = o.UserInfo
+	default:
+		fmt.Printf("⚠️  未知的流量信息写入方式 %q，改为 comment\n", o.UserInfo)
+		c.UserInfo = "comment"
 	}
-
-	rules := downloadRules()
-	processRule(&sb, rules[UrlLan], "🎯 全球直连", "", exclusionMap)
-	if !c.IsNoReject {
-		processRule(&sb, rules[UrlBanAD], "🛑 广告拦截", "", exclusionMap)
-		if c.UseAdblockPlus { processRule(&sb, rules[UrlBanProgramAD], "🛑 广告拦截", "", exclusionMap) }
-	}
-	if !c.IsMini {
-		processRule(&sb, rules[UrlMicrosoft], "Ⓜ️ 微软服务", "", exclusionMap)
-		processRule(&sb, rules[UrlApple], "🍎 苹果服务", "", exclusionMap)
-		processRule(&sb, rules[UrlGoogle], c.TargetGoogle, "", exclusionMap)
-		processRule(&sb, rules[UrlTelegram], "📲 电报消息", "", exclusionMap)
-		processRule(&sb, rules[UrlNetflix], c.TargetNetflix, "", exclusionMap)
-		processRule(&sb, rules[UrlProxyLite], "🚀 节点选择", "", exclusionMap)
-		if c.IsFull {
-			processRule(&sb, rules[UrlOneDrive], "☁️ 微软云盘", "", exclusionMap)
-			processRule(&sb, rules[UrlSteamCN], "🚂 Steam", "", exclusionMap)
-			processRule(&sb, rules[UrlGames], "🎮 游戏服务", "", exclusionMap)
-		}
-		processRule(&sb, rules[UrlMedia], "🌍 国外媒体", "", exclusionMap)
-	} else {
-		processRule(&sb, rules[UrlProxyLite], "🚀 节点选择", "", exclusionMap)
-		processRule(&sb, rules[UrlGoogle], "🚀 节点选择", "", exclusionMap)
-		processRule(&sb, rules[UrlTelegram], "🚀 节点选择", "", exclusionMap)
+	if o.ProviderType == "" { o.ProviderType = settings.RuleProviderType }
+	if o.ProviderInterval == 0 { o.ProviderInterval = settings.RuleProviderInterval }
+	switch o.ProviderType {
+	case "", "inline":
+	case "http", "file":
+		c.RuleProviderType = o.ProviderType
+		c.RuleProviderInterval = o.ProviderInterval
+	default:
+		fmt.Printf("⚠️  未知的 rule-providers 类型 %q，改为内联规则\n", o.ProviderType)
 	}
-	processRule(&sb, rules[UrlChinaDomain], "🎯 全球直连", "", exclusionMap)
-	processRule(&sb, rules[UrlChinaIP], "🎯 全球直连", "no-resolve", exclusionMap)
-	sb.WriteString("  - MATCH,🐟 漏网之鱼\n")
-
-	return sb.String()
+	return c, nil
 }
 
 // --- 辅助函数 ---
@@ -392,111 +637,76 @@ func writeNode(sb *strings.Builder, n Node) {
 	}
 }
 
-func writeAutoGroup(sb *strings.Builder, name, gType string, nodes []Node) {
-	sb.WriteString(fmt.Sprintf("  - name: %s\n    type: %s\n", name, gType))
-	if gType != "select" {
-		sb.WriteString("    url: http://www.gstatic.com/generate_204\n    interval: 300\n    tolerance: 50\n")
-	}
-	sb.WriteString("    proxies:\n")
-	for _, n := range nodes { sb.WriteString(fmt.Sprintf("      - %s\n", n.Name)) }
-}
+// stringList 是可重复指定的命令行参数
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
 
-func writeProxyGroup(sb *strings.Builder, name, gType string) {
-	sb.WriteString(fmt.Sprintf("  - name: %s\n    type: %s\n", name, gType))
-	sb.WriteString("    proxies:\n      - 🚀 节点选择\n      - ♻️ 自动选择\n      - 🎯 全球直连\n")
+// isFlagSet 判断命令行是否显式指定了某个参数
+func isFlagSet(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name { found = true }
+	})
+	return found
 }
 
-func downloadRules() map[string]string {
-	urls := []string{
-		UrlLan, UrlBanAD, UrlBanProgramAD, UrlChinaDomain, UrlChinaIP, 
-		UrlProxyLite, UrlApple, UrlMicrosoft, UrlGoogle, UrlTelegram, 
-		UrlNetflix, UrlMedia, UrlSteamCN, UrlGames, UrlOneDrive,
-	}
-	res := make(map[string]string)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	client := http.Client{Timeout: 30 * time.Second}
-	for _, u := range urls {
-		wg.Add(1)
-		go func(urlStr string) {
-			defer wg.Done()
-			resp, err := client.Get(urlStr)
-			if err == nil {
-				defer resp.Body.Close()
-				b, _ := io.ReadAll(resp.Body)
-				mu.Lock()
-				res[urlStr] = string(b)
-				mu.Unlock()
-			}
-		}(u)
-	}
-	wg.Wait()
-	return res
+func pause(scanner *bufio.Scanner) {
+	fmt.Println("\n按回车键退出...")
+	scanner.Scan()
 }
 
-func processRule(sb *strings.Builder, content, target, extra string, exclusionMap map[string]bool) {
-	if content == "" { return }
-	for _, line := range strings.Split(content, "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") { continue }
-		if idx := strings.Index(line, "#"); idx > 0 { line = strings.TrimSpace(line[:idx]) }
-		parts := strings.Split(line, ",")
-		if len(parts) >= 2 {
-			if exclusionMap[strings.TrimSpace(strings.ToLower(parts[1]))] { continue }
-		}
-		if strings.Contains(line, ",") {
-			if len(parts) < 2 { continue }
-			if extra != "" {
-				sb.WriteString(fmt.Sprintf("  - %s,%s,%s,%s\n", parts[0], parts[1], target, extra))
-			} else {
-				sb.WriteString(fmt.Sprintf("  - %s,%s,%s\n", parts[0], parts[1], target))
-			}
-		} else {
-			if strings.Contains(line, "/") {
-				sb.WriteString(fmt.Sprintf("  - IP-CIDR,%s,%s,no-resolve\n", line, target))
-			} else {
-				sb.WriteString(fmt.Sprintf("  - DOMAIN-SUFFIX,%s,%s\n", line, target))
-			}
-		}
+// --- 链接解析器 ---
+
+var errUnknownLink = errors.New("不支持的链接类型")
+
+// parseLink 按协议前缀解析一条分享链接，proto 用于输出提示
+func parseLink(line string) (node Node, proto string, err error) {
+	switch {
+	case strings.HasPrefix(line, "vless://"):
+		node, err = parseVless(line)
+		return node, "VLESS", err
+	case strings.HasPrefix(line, "hy2://") || strings.HasPrefix(line, "hysteria2://"):
+		node, err = parseHy2(line)
+		return node, "Hy2", err
+	case strings.HasPrefix(line, "ss://"):
+		node, err = parseSS(line)
+		return node, "SS", err
 	}
+	if m := otherScheme.FindStringSubmatch(line); m != nil {
+		return Node{}, strings.ToUpper(m[1]), fmt.Errorf("暂不支持 %s:// 链接", strings.ToLower(m[1]))
+	}
+	return Node{}, "", errUnknownLink
 }
 
-func classifyNodes(nodes []Node) map[string][]string {
-	groups := map[string][]string{ "HK": {}, "TW": {}, "JP": {}, "SG": {}, "US": {}, "Other": {} }
-	regHK := regexp.MustCompile(`(?i)(HK|Hong|Kong|香港|🇭🇰)`)
-	regTW := regexp.MustCompile(`(?i)(TW|Taiwan|台湾|🇹🇼)`)
-	regJP := regexp.MustCompile(`(?i)(JP|Japan|日本|🇯🇵)`)
-	regSG := regexp.MustCompile(`(?i)(SG|Singapore|新加坡|🦁|🇸🇬)`)
-	regUS := regexp.MustCompile(`(?i)(US|America|States|美国|🇺🇸)`)
-	for _, n := range nodes {
-		if regHK.MatchString(n.Name) { groups["HK"] = append(groups["HK"], n.Name)
-		} else if regTW.MatchString(n.Name) { groups["TW"] = append(groups["TW"], n.Name)
-		} else if regJP.MatchString(n.Name) { groups["JP"] = append(groups["JP"], n.Name)
-		} else if regSG.MatchString(n.Name) { groups["SG"] = append(groups["SG"], n.Name)
-		} else if regUS.MatchString(n.Name) { groups["US"] = append(groups["US"], n.Name)
-		} else { groups["Other"] = append(groups["Other"], n.Name) }
-	}
-	return groups
-}
+var otherScheme = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*)://`)
 
-func getCountryGroupName(code string) string {
-	switch code {
-	case "HK": return "🇭🇰 香港节点"
-	case "TW": return "🇹🇼 台湾节点"
-	case "JP": return "🇯🇵 日本节点"
-	case "SG": return "🇸🇬 新加坡节点"
-	case "US": return "🇺🇸 美国节点"
-	default: return "🏳️‍🌈 其他地区"
+// parseLinks 整理多行文本后逐条解析诊断，无法识别的行记为拒绝
+func parseLinks(text string) []LinkResult {
+	var results []LinkResult
+	var in linkInput
+	check := func(lines []inputLine) {
+		for _, l := range lines {
+			results = append(results, checkLink(l.No, l.Text))
+		}
+	}
+	for i, line := range inputLines(text) {
+		check(in.feed(i+1, line))
 	}
+	check(in.flush())
+	return results
 }
 
-func pause(scanner *bufio.Scanner) {
-	fmt.Println("\n按回车键退出...")
-	scanner.Scan()
+// acceptedNodes 返回未被拒绝的节点 (含降级的)
+func acceptedNodes(results []LinkResult) []Node {
+	var nodes []Node
+	for _, r := range results {
+		if r.OK() { nodes = append(nodes, r.Node) }
+	}
+	return nodes
 }
 
-// --- 链接解析器 ---
-
 func decodeBase64(s string) (string, error) {
 	s = strings.TrimSpace(s)
 	s = strings.ReplaceAll(s, "-", "+")
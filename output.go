@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- 输出文件 ---
+
+// 默认保留的备份数量
+const defaultBackups = 5
+
+// writeFileAtomic 先写同目录下的临时文件再改名，Clash 不会读到写了一半的配置
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// replaceOutput 备份现有文件后原子写入新内容；keep 为 0 时不备份
+func replaceOutput(path string, data []byte, keep int) (backup string, err error) {
+	if keep > 0 {
+		if backup, err = backupFile(path, keep); err != nil {
+			return "", fmt.Errorf("备份失败: %v", err)
+		}
+	}
+	return backup, writeFileAtomic(path, data)
+}
+
+// backupFile 把现有文件复制为 文件名.20060102-150405.bak，只保留最新的 keep 个；文件不存在时不备份
+func backupFile(path string, keep int) (string, error) {
+	old, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	backup := path + "." + time.Now().Format("20060102-150405") + ".bak"
+	if err := writeFileAtomic(backup, old); err != nil {
+		return "", err
+	}
+	// 时间戳定长，按文件名排序即按时间排序
+	olds, _ := filepath.Glob(path + ".*.bak")
+	sort.Strings(olds)
+	for len(olds) > keep {
+		os.Remove(olds[0])
+		olds = olds[1:]
+	}
+	return backup, nil
+}
+
+// --- 配置差异 ---
+
+// configSummary 是从 YAML 文本中粗略提取的节点、策略组 (名称 → 原文) 和规则数，
+// 只依赖缩进结构，其他工具生成的配置也能比较
+type configSummary struct {
+	Nodes  map[string]string
+	Groups map[string]string
+	Rules  int
+}
+
+var yamlItemName = regexp.MustCompile(`^\s*-?\s*\{?\s*name:\s*(.+?)\s*(?:,|\}|$)`)
+
+func summarizeConfig(content string) configSummary {
+	s := configSummary{Nodes: map[string]string{}, Groups: map[string]string{}}
+	section := ""
+	var name string
+	var item strings.Builder
+	flush := func() {
+		if name != "" {
+			switch section {
+			case "proxies":
+				s.Nodes[name] = item.String()
+			case "proxy-groups":
+				s.Groups[name] = item.String()
+			}
+		}
+		name = ""
+		item.Reset()
+	}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '-' {
+			flush()
+			section = strings.TrimSuffix(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]), ":")
+			continue
+		}
+		if strings.HasPrefix(line, "  - ") || strings.HasPrefix(line, "- ") {
+			flush()
+			if section == "rules" {
+				s.Rules++
+			}
+		}
+		if section != "proxies" && section != "proxy-groups" {
+			continue
+		}
+		if name == "" {
+			if m := yamlItemName.FindStringSubmatch(line); m != nil {
+				name = strings.Trim(m[1], `'"`)
+			}
+		}
+		item.WriteString(strings.TrimSpace(line) + "\n")
+	}
+	flush()
+	return s
+}
+
+// nameDiff 比较两组 名称 → 原文，返回新增、移除和内容有变化的名称
+func nameDiff(old, cur map[string]string) (added, removed, changed []string) {
+	for n, v := range cur {
+		if o, ok := old[n]; !ok {
+			added = append(added, n)
+		} else if o != v {
+			changed = append(changed, n)
+		}
+	}
+	for n := range old {
+		if _, ok := cur[n]; !ok {
+			removed = append(removed, n)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return
+}
+
+// diffSummary 生成与现有文件相比的差异摘要，没有差异时返回 nil
+func diffSummary(oldContent, newContent string) []string {
+	if oldContent == newContent {
+		return nil
+	}
+	o, n := summarizeConfig(oldContent), summarizeConfig(newContent)
+	var lines []string
+	section := func(title string, old, cur map[string]string) {
+		a, r, c := nameDiff(old, cur)
+		if len(a)+len(r)+len(c) == 0 {
+			return
+		}
+		line := fmt.Sprintf("%s：%d → %d (+%d -%d ~%d)", title, len(old), len(cur), len(a), len(r), len(c))
+		var parts []string
+		if len(a) > 0 {
+			parts = append(parts, "新增 "+nameList(a))
+		}
+		if len(r) > 0 {
+			parts = append(parts, "移除 "+nameList(r))
+		}
+		if len(c) > 0 {
+			parts = append(parts, "变更 "+nameList(c))
+		}
+		lines = append(lines, line, "   "+strings.Join(parts, "；"))
+	}
+	section("节点", o.Nodes, n.Nodes)
+	section("策略组", o.Groups, n.Groups)
+	if o.Rules != n.Rules {
+		lines = append(lines, fmt.Sprintf("规则：%d → %d (%+d)", o.Rules, n.Rules, n.Rules-o.Rules))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "节点、策略组和规则数量未变，其他设置有改动")
+	}
+	return lines
+}
+
+func nameList(names []string) string {
+	const max = 5
+	if len(names) <= max {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s 等 %d 个", strings.Join(names[:max], ", "), len(names))
+}
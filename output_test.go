@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const oldConfig = `port: 7890
+proxies:
+  - {name: 香港 01, type: ss, server: a.example.com, port: 8388}
+  - name: "日本 01"
+    type: vless
+    server: b.example.com
+  - {name: 美国 01, type: ss, server: c.example.com, port: 8388}
+proxy-groups:
+  - name: 🚀 节点选择
+    type: select
+    proxies:
+      - 香港 01
+      - 日本 01
+rules:
+  - DOMAIN-SUFFIX,cn,DIRECT
+  - MATCH,🚀 节点选择
+`
+
+func TestSummarizeConfig(t *testing.T) {
+	s := summarizeConfig(strings.ReplaceAll(oldConfig, "\n", "\r\n"))
+	var nodes []string
+	for n := range s.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	if want := []string{"日本 01", "美国 01", "香港 01"}; !reflect.DeepEqual(nodes, want) {
+		t.Errorf("节点 %q，应为 %q", nodes, want)
+	}
+	if want := "- name: \"日本 01\"\ntype: vless\nserver: b.example.com\n"; s.Nodes["日本 01"] != want {
+		t.Errorf("节点原文 %q，应为 %q", s.Nodes["日本 01"], want)
+	}
+	if len(s.Groups) != 1 || !strings.Contains(s.Groups["🚀 节点选择"], "- 日本 01\n") {
+		t.Errorf("策略组 %q", s.Groups)
+	}
+	if s.Rules != 2 {
+		t.Errorf("规则 %d 条，应为 2", s.Rules)
+	}
+}
+
+func TestDiffSummary(t *testing.T) {
+	if lines := diffSummary(oldConfig, oldConfig); lines != nil {
+		t.Errorf("内容相同时应没有差异: %q", lines)
+	}
+	cur := strings.NewReplacer(
+		"  - {name: 美国 01, type: ss, server: c.example.com, port: 8388}\n",
+		"  - {name: 新加坡 01, type: ss, server: d.example.com, port: 8388}\n",
+		"server: b.example.com", "server: b2.example.com",
+		"  - MATCH,", "  - GEOIP,CN,DIRECT\n  - MATCH,",
+	).Replace(oldConfig)
+	want := []string{
+		"节点：3 → 3 (+1 -1 ~1)",
+		"   新增 新加坡 01；移除 美国 01；变更 日本 01",
+		"规则：2 → 3 (+1)",
+	}
+	if lines := diffSummary(oldConfig, cur); !reflect.DeepEqual(lines, want) {
+		t.Errorf("差异 %q，应为 %q", lines, want)
+	}
+	other := strings.Replace(oldConfig, "port: 7890", "port: 7891", 1)
+	if lines := diffSummary(oldConfig, other); len(lines) != 1 || !strings.Contains(lines[0], "其他设置有改动") {
+		t.Errorf("只改全局设置时: %q", lines)
+	}
+}
+
+func TestNameList(t *testing.T) {
+	if got := nameList([]string{"a", "b"}); got != "a, b" {
+		t.Errorf("nameList = %q", got)
+	}
+	if got := nameList([]string{"a", "b", "c", "d", "e", "f", "g"}); got != "a, b, c, d, e 等 7 个" {
+		t.Errorf("nameList = %q", got)
+	}
+}
+
+func TestBackupFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if backup, err := backupFile(path, 2); err != nil || backup != "" {
+		t.Fatalf("文件不存在时不应备份: %q, %v", backup, err)
+	}
+	for _, ts := range []string{"20200101-000000", "20200102-000000", "20200103-000000"} {
+		if err := os.WriteFile(path+"."+ts+".bak", []byte(ts), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, []byte("current"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := backupFile(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(backup); err != nil || string(b) != "current" {
+		t.Errorf("备份内容 %q, %v", b, err)
+	}
+	olds, _ := filepath.Glob(path + ".*.bak")
+	sort.Strings(olds)
+	if want := []string{path + ".20200103-000000.bak", backup}; !reflect.DeepEqual(olds, want) {
+		t.Errorf("保留的备份 %q，应为 %q", olds, want)
+	}
+}
+
+func TestReplaceOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := replaceOutput(path, []byte("new"), 0)
+	if err != nil || backup != "" {
+		t.Fatalf("keep 为 0 时不应备份: %q, %v", backup, err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "new" {
+		t.Errorf("写入后内容 %q", b)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("目录中留下了多余的文件: %v", entries)
+	}
+}
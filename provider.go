@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// --- 节点集 (proxy-providers) ---
+
+// ProxyProvider 是输出到 proxy-providers 的远程/本地节点列表。
+// 使用后配置中不再内联节点，策略组改用 use + filter，节点变化时无需重新生成配置
+type ProxyProvider struct {
+	Name     string
+	Type     string // http 或 file
+	URL      string // http 类型的订阅地址
+	Path     string // 本地缓存/文件路径
+	Interval int    // http 类型的更新间隔 (秒)
+}
+
+// 节点订阅的默认更新间隔，与 rule-providers 相同
+const defaultProxyProviderInterval = 86400
+
+// parseProxyProvider 解析 [名称=]订阅地址或文件路径；省略名称时取主机名或文件名
+func parseProxyProvider(v string) (ProxyProvider, error) {
+	var p ProxyProvider
+	v = strings.TrimSpace(v)
+	if i := strings.Index(v, "="); i > 0 && !strings.Contains(v[:i], "://") {
+		p.Name, v = strings.TrimSpace(v[:i]), strings.TrimSpace(v[i+1:])
+		if err := checkSourceLabel(p.Name); err != nil {
+			return p, err
+		}
+	}
+	if v == "" {
+		return p, fmt.Errorf("缺少订阅地址或文件路径")
+	}
+	if strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") {
+		u, err := url.Parse(v)
+		if err != nil || u.Host == "" {
+			return p, fmt.Errorf("无效的订阅地址")
+		}
+		if p.Name == "" {
+			p.Name = u.Hostname()
+		}
+		p.Type, p.URL, p.Interval = "http", v, defaultProxyProviderInterval
+		p.Path = "./proxy_providers/" + p.Name + ".yaml"
+		return p, nil
+	}
+	if strings.Contains(v, "://") {
+		return p, fmt.Errorf("只支持 http(s) 订阅地址或本地文件")
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(v), filepath.Ext(v))
+		if checkSourceLabel(p.Name) != nil {
+			return p, fmt.Errorf("文件名 %q 不能直接作为名称，请写成 名称=路径", p.Name)
+		}
+	}
+	p.Type, p.Path = "file", v
+	return p, nil
+}
+
+func writeProxyProvider(sb *strings.Builder, p ProxyProvider, h HealthCheck) {
+	sb.WriteString(fmt.Sprintf("  %s:\n    type: %s\n", yamlString(p.Name), p.Type))
+	if p.Type == "http" {
+		sb.WriteString(fmt.Sprintf("    url: %s\n    interval: %d\n", yamlString(p.URL), p.Interval))
+	}
+	sb.WriteString(fmt.Sprintf("    path: %s\n", yamlString(p.Path)))
+	// 节点集自身的健康检查，供 use 了它的测速组之外的 select 组显示延迟
+	url, interval := h.URL, h.Interval
+	if url == "" {
+		url = regionGroupTemplate.URL
+	}
+	if interval <= 0 {
+		interval = regionGroupTemplate.Interval
+	}
+	sb.WriteString(fmt.Sprintf("    health-check:\n      enable: true\n      url: %s\n      interval: %d\n", url, interval))
+	if h.Timeout > 0 {
+		sb.WriteString(fmt.Sprintf("      timeout: %d\n", h.Timeout))
+	}
+	lazy := true
+	if h.Lazy != nil {
+		lazy = *h.Lazy
+	}
+	sb.WriteString(fmt.Sprintf("      lazy: %v\n", lazy))
+}
+
+// providerNames 返回全部节点集名称，用于策略组的 use
+func providerNames(ps []ProxyProvider) []string {
+	var names []string
+	for _, p := range ps {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// regionFilter 把地区表中的写法转成节点名过滤正则，匹配规则与 detectRegion 相同：
+// 国旗和中文按子串，全大写短代码区分大小写，其余英文忽略大小写，代码和英文都按单词边界
+func regionFilter(code string) string {
+	r, ok := regionTable[code]
+	if !ok {
+		return ""
+	}
+	parts := []string{r.Flag()}
+	var codes, words []string
+	for _, t := range append([]string{r.Name, r.EnName, r.Code}, r.Aliases...) {
+		switch {
+		case !isASCII(t):
+			parts = append(parts, regexp.QuoteMeta(t))
+		case len(t) <= 3 && strings.ToUpper(t) == t:
+			codes = append(codes, regexp.QuoteMeta(t))
+		default:
+			words = append(words, regexp.QuoteMeta(strings.ToLower(t)))
+		}
+	}
+	if len(codes) > 0 {
+		parts = append(parts, "(?:^|[^A-Za-z0-9])(?:"+strings.Join(codes, "|")+")(?:[^A-Za-z]|$)")
+	}
+	if len(words) > 0 {
+		parts = append(parts, "(?i:(?:^|[^A-Za-z])(?:"+strings.Join(words, "|")+")(?:[^A-Za-z]|$))")
+	}
+	return strings.Join(parts, "|")
+}
+
+// joinFilters 合并多个过滤正则，任一为空 (匹配全部) 时返回空
+func joinFilters(fs []string) string {
+	if len(fs) == 1 {
+		return fs[0]
+	}
+	var parts []string
+	for _, f := range fs {
+		if f == "" {
+			return ""
+		}
+		parts = append(parts, "(?:"+f+")")
+	}
+	return strings.Join(parts, "|")
+}
+
+// providerRegionGroup 生成使用节点集的地区分组；Other 组排除其余已列出的地区
+func providerRegionGroup(code string, codes []string, use []string) ProxyGroup {
+	rg := regionGroupTemplate
+	rg.Name = getCountryGroupName(code)
+	g := groupFromTemplate(rg)
+	g.Use = use
+	if code != "Other" {
+		g.Filter = regionFilter(code)
+		return g
+	}
+	var others []string
+	for _, c := range codes {
+		if c != "Other" {
+			others = append(others, regionFilter(c))
+		}
+	}
+	g.ExcludeFilter = joinFilters(others)
+	return g
+}
+
+// providerGroups 为每个节点集生成一个手动选择组，与来源分组相同；只有一个节点集时不生成
+func providerGroups(ps []ProxyProvider) []ProxyGroup {
+	if len(ps) < 2 {
+		return nil
+	}
+	var out []ProxyGroup
+	for _, p := range ps {
+		out = append(out, ProxyGroup{Name: sourceGroupName(p.Name), Type: "select", Use: []string{p.Name}})
+	}
+	return out
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// --- 地区识别 ---
+
+// Region 描述一个国家/地区及其可用于匹配节点名的别名
+type Region struct {
+	Code    string   // ISO 3166-1 两位代码
+	Name    string   // 中文名
+	EnName  string   // 英文名
+	Aliases []string // 其他写法：英文别名、中文简称、机场代码、emoji
+}
+
+// Flag 由 ISO 代码计算国旗 emoji (区域指示符号)
+func (r Region) Flag() string {
+	if len(r.Code) != 2 {
+		return "🏳️"
+	}
+	var b strings.Builder
+	for _, c := range strings.ToUpper(r.Code) {
+		b.WriteRune(0x1F1E6 + (c - 'A'))
+	}
+	return b.String()
+}
+
+// 内置地区表，格式与配置文件 [regions] 小节相同：
+// 代码=中文名,英文名[,别名...]
+// 全大写且不超过 3 位的别名 (ISO 代码、机场代码) 按单词边界区分大小写匹配，
+// 其余英文别名忽略大小写按单词边界匹配，中文与 emoji 按子串匹配；
+// 单个汉字的简称 (港、台) 前后不能紧挨其他汉字，"港口"、"台式机" 不会误识别。
+const builtinRegions = `
+HK=香港,Hong Kong,HongKong,HKG,中国香港,港
+TW=台湾,Taiwan,TPE,TSA,KHH,中国台湾,台北,新北,彰化,台
+MO=澳门,Macau,Macao,MFM,中国澳门
+JP=日本,Japan,NRT,HND,KIX,ITM,NGO,FUK,CTS,OKA,东京,大阪,埼玉,名古屋,川日,泉日,Tokyo,Osaka
+SG=新加坡,Singapore,SIN,狮城,🦁
+US=美国,United States,USA,America,LAX,SJC,SFO,SEA,JFK,EWR,ORD,DFW,IAD,ATL,MIA,PHX,DEN,LAS,PDX,洛杉矶,圣何塞,硅谷,西雅图,芝加哥,纽约,达拉斯,凤凰城,波特兰,费利蒙,Los Angeles,San Jose,New Jersey,Silicon Valley,Seattle,Chicago,New York,Dallas
+KR=韩国,Korea,South Korea,ICN,GMP,首尔,春川,Seoul
+KP=朝鲜,North Korea
+CN=中国,China,回国,PEK,PVG,SHA,SZX,北京,上海,广州,深圳,杭州
+GB=英国,United Kingdom,Britain,England,UK,LHR,LGW,伦敦,London
+DE=德国,Germany,FRA,MUC,TXL,BER,法兰克福,柏林,Frankfurt,Berlin
+FR=法国,France,CDG,ORY,MRS,巴黎,马赛,Paris
+NL=荷兰,Netherlands,Holland,AMS,阿姆斯特丹,Amsterdam
+RU=俄罗斯,Russia,Russian Federation,SVO,DME,莫斯科,伯力,圣彼得堡,Moscow
+CA=加拿大,Canada,YYZ,YVR,YUL,多伦多,温哥华,蒙特利尔,Toronto,Vancouver,Montreal
+AU=澳大利亚,Australia,SYD,MEL,BNE,澳洲,悉尼,墨尔本,Sydney,Melbourne
+NZ=新西兰,New Zealand,AKL,奥克兰,Auckland
+IN=印度,India,BOM,DEL,MAA,BLR,孟买,新德里,Mumbai
+ID=印度尼西亚,Indonesia,CGK,印尼,雅加达,Jakarta
+MY=马来西亚,Malaysia,KUL,吉隆坡,大马,Kuala Lumpur
+TH=泰国,Thailand,BKK,DMK,曼谷,Bangkok
+VN=越南,Vietnam,Viet Nam,SGN,HAN,胡志明,河内
+PH=菲律宾,Philippines,MNL,马尼拉,Manila
+TR=土耳其,Turkey,Türkiye,IST,伊斯坦布尔,Istanbul
+AE=阿联酋,United Arab Emirates,UAE,DXB,AUH,迪拜,阿布扎比,Dubai
+AF=阿富汗,Afghanistan
+AX=奥兰群岛,Aland Islands,Åland Islands
+AL=阿尔巴尼亚,Albania
+DZ=阿尔及利亚,Algeria
+AS=美属萨摩亚,American Samoa
+AD=安道尔,Andorra
+AO=安哥拉,Angola
+AI=安圭拉,Anguilla
+AQ=南极洲,Antarctica
+AG=安提瓜和巴布达,Antigua and Barbuda
+AR=阿根廷,Argentina,EZE,布宜诺斯艾利斯,Buenos Aires
+AM=亚美尼亚,Armenia
+AW=阿鲁巴,Aruba
+AT=奥地利,Austria,VIE,维也纳,Vienna
+AZ=阿塞拜疆,Azerbaijan
+BS=巴哈马,Bahamas
+BH=巴林,Bahrain
+BD=孟加拉国,Bangladesh,孟加拉
+BB=巴巴多斯,Barbados
+BY=白俄罗斯,Belarus
+BE=比利时,Belgium,BRU,布鲁塞尔,Brussels
+BZ=伯利兹,Belize
+BJ=贝宁,Benin
+BM=百慕大,Bermuda
+BT=不丹,Bhutan
+BO=玻利维亚,Bolivia
+BQ=荷兰加勒比区,Caribbean Netherlands,Bonaire
+BA=波黑,Bosnia and Herzegovina,波斯尼亚和黑塞哥维那
+BW=博茨瓦纳,Botswana
+BV=布韦岛,Bouvet Island
+BR=巴西,Brazil,GRU,GIG,圣保罗,Sao Paulo,São Paulo
+IO=英属印度洋领地,British Indian Ocean Territory
+BN=文莱,Brunei
+BG=保加利亚,Bulgaria,SOF,索菲亚,Sofia
+BF=布基纳法索,Burkina Faso
+BI=布隆迪,Burundi
+CV=佛得角,Cape Verde,Cabo Verde
+KH=柬埔寨,Cambodia,PNH,金边,Phnom Penh
+CM=喀麦隆,Cameroon
+KY=开曼群岛,Cayman Islands
+CF=中非,Central African Republic
+TD=乍得,Chad
+CL=智利,Chile,SCL,圣地亚哥,Santiago
+CX=圣诞岛,Christmas Island
+CC=科科斯群岛,Cocos Islands
+CO=哥伦比亚,Colombia,BOG,波哥大,Bogota
+KM=科摩罗,Comoros
+CG=刚果(布),Republic of the Congo,刚果共和国
+CD=刚果(金),DR Congo,Democratic Republic of the Congo,刚果民主共和国
+CK=库克群岛,Cook Islands
+CR=哥斯达黎加,Costa Rica
+CI=科特迪瓦,Cote d'Ivoire,Ivory Coast
+HR=克罗地亚,Croatia,ZAG,萨格勒布
+CU=古巴,Cuba
+CW=库拉索,Curacao,Curaçao
+CY=塞浦路斯,Cyprus
+CZ=捷克,Czech,Czechia,Czech Republic,PRG,布拉格,Prague
+DK=丹麦,Denmark,CPH,哥本哈根,Copenhagen
+DJ=吉布提,Djibouti
+DM=多米尼克,Dominica
+DO=多米尼加,Dominican Republic
+EC=厄瓜多尔,Ecuador
+EG=埃及,Egypt,CAI,开罗,Cairo
+SV=萨尔瓦多,El Salvador
+GQ=赤道几内亚,Equatorial Guinea
+ER=厄立特里亚,Eritrea
+EE=爱沙尼亚,Estonia,TLL,塔林,Tallinn
+SZ=斯威士兰,Eswatini,Swaziland
+ET=埃塞俄比亚,Ethiopia
+FK=福克兰群岛,Falkland Islands,马尔维纳斯群岛
+FO=法罗群岛,Faroe Islands
+FJ=斐济,Fiji
+FI=芬兰,Finland,HEL,赫尔辛基,Helsinki
+GF=法属圭亚那,French Guiana
+PF=法属波利尼西亚,French Polynesia
+TF=法属南部领地,French Southern Territories
+GA=加蓬,Gabon
+GM=冈比亚,Gambia
+GE=格鲁吉亚,Georgia,TBS,第比利斯,Tbilisi
+GH=加纳,Ghana
+GI=直布罗陀,Gibraltar
+GR=希腊,Greece,ATH,雅典,Athens
+GL=格陵兰,Greenland
+GD=格林纳达,Grenada
+GP=瓜德罗普,Guadeloupe
+GU=关岛,Guam
+GT=危地马拉,Guatemala
+GG=根西岛,Guernsey
+GN=几内亚,Guinea
+GW=几内亚比绍,Guinea-Bissau
+GY=圭亚那,Guyana
+HT=海地,Haiti
+HM=赫德岛和麦克唐纳群岛,Heard Island and McDonald Islands
+VA=梵蒂冈,Vatican,Holy See
+HN=洪都拉斯,Honduras
+HU=匈牙利,Hungary,BUD,布达佩斯,Budapest
+IS=冰岛,Iceland,KEF,雷克雅未克,Reykjavik
+IR=伊朗,Iran,THR,德黑兰,Tehran
+IQ=伊拉克,Iraq,巴格达
+IE=爱尔兰,Ireland,DUB,都柏林,Dublin
+IM=马恩岛,Isle of Man
+IL=以色列,Israel,TLV,特拉维夫,Tel Aviv
+IT=意大利,Italy,MXP,FCO,米兰,罗马,Milan,Rome
+JM=牙买加,Jamaica
+JE=泽西岛,Jersey
+JO=约旦,Jordan
+KZ=哈萨克斯坦,Kazakhstan,ALA,阿拉木图,Almaty
+KE=肯尼亚,Kenya,NBO,内罗毕,Nairobi
+KI=基里巴斯,Kiribati
+KW=科威特,Kuwait
+KG=吉尔吉斯斯坦,Kyrgyzstan
+LA=老挝,Laos,Lao
+LV=拉脱维亚,Latvia,RIX,里加,Riga
+LB=黎巴嫩,Lebanon
+LS=莱索托,Lesotho
+LR=利比里亚,Liberia
+LY=利比亚,Libya
+LI=列支敦士登,Liechtenstein
+LT=立陶宛,Lithuania,VNO,维尔纽斯,Vilnius
+LU=卢森堡,Luxembourg,LUX
+MG=马达加斯加,Madagascar
+MW=马拉维,Malawi
+MV=马尔代夫,Maldives
+ML=马里,Mali
+MT=马耳他,Malta
+MH=马绍尔群岛,Marshall Islands
+MQ=马提尼克,Martinique
+MR=毛里塔尼亚,Mauritania
+MU=毛里求斯,Mauritius
+YT=马约特,Mayotte
+MX=墨西哥,Mexico,MEX,墨西哥城,Mexico City
+FM=密克罗尼西亚,Micronesia
+MD=摩尔多瓦,Moldova,KIV,基希讷乌
+MC=摩纳哥,Monaco
+MN=蒙古,Mongolia,ULN,乌兰巴托,Ulaanbaatar
+ME=黑山,Montenegro
+MS=蒙特塞拉特,Montserrat
+MA=摩洛哥,Morocco,CMN,卡萨布兰卡,Casablanca
+MZ=莫桑比克,Mozambique
+MM=缅甸,Myanmar,Burma,RGN,仰光,Yangon
+NA=纳米比亚,Namibia
+NR=瑙鲁,Nauru
+NP=尼泊尔,Nepal,KTM,加德满都,Kathmandu
+NC=新喀里多尼亚,New Caledonia
+NI=尼加拉瓜,Nicaragua
+NE=尼日尔,Niger
+NG=尼日利亚,Nigeria,拉各斯,Lagos
+NU=纽埃,Niue
+NF=诺福克岛,Norfolk Island
+MK=北马其顿,North Macedonia,Macedonia,马其顿
+MP=北马里亚纳群岛,Northern Mariana Islands,塞班,Saipan
+NO=挪威,Norway,OSL,奥斯陆,Oslo
+OM=阿曼,Oman
+PK=巴基斯坦,Pakistan,KHI,卡拉奇,Karachi
+PW=帕劳,Palau
+PS=巴勒斯坦,Palestine
+PA=巴拿马,Panama,PTY
+PG=巴布亚新几内亚,Papua New Guinea
+PY=巴拉圭,Paraguay
+PE=秘鲁,Peru,LIM,利马,Lima
+PN=皮特凯恩群岛,Pitcairn Islands
+PL=波兰,Poland,WAW,华沙,Warsaw
+PT=葡萄牙,Portugal,LIS,里斯本,Lisbon
+PR=波多黎各,Puerto Rico,SJU
+QA=卡塔尔,Qatar,DOH,多哈,Doha
+RE=留尼汪,Reunion,Réunion
+RO=罗马尼亚,Romania,OTP,布加勒斯特,Bucharest
+RW=卢旺达,Rwanda
+BL=圣巴泰勒米,Saint Barthelemy
+SH=圣赫勒拿,Saint Helena
+KN=圣基茨和尼维斯,Saint Kitts and Nevis
+LC=圣卢西亚,Saint Lucia
+MF=法属圣马丁,Saint Martin
+PM=圣皮埃尔和密克隆,Saint Pierre and Miquelon
+VC=圣文森特和格林纳丁斯,Saint Vincent and the Grenadines
+WS=萨摩亚,Samoa
+SM=圣马力诺,San Marino
+ST=圣多美和普林西比,Sao Tome and Principe
+SA=沙特阿拉伯,Saudi Arabia,RUH,JED,沙特,利雅得,Riyadh
+SN=塞内加尔,Senegal
+RS=塞尔维亚,Serbia,BEG,贝尔格莱德,Belgrade
+SC=塞舌尔,Seychelles
+SL=塞拉利昂,Sierra Leone
+SX=荷属圣马丁,Sint Maarten
+SK=斯洛伐克,Slovakia,BTS,布拉迪斯拉发
+SI=斯洛文尼亚,Slovenia,LJU,卢布尔雅那
+SB=所罗门群岛,Solomon Islands
+SO=索马里,Somalia
+ZA=南非,South Africa,JNB,CPT,约翰内斯堡,开普敦,Johannesburg
+GS=南乔治亚和南桑威奇群岛,South Georgia and the South Sandwich Islands
+SS=南苏丹,South Sudan
+ES=西班牙,Spain,MAD,BCN,马德里,巴塞罗那,Madrid,Barcelona
+LK=斯里兰卡,Sri Lanka,CMB,科伦坡,Colombo
+SD=苏丹,Sudan
+SR=苏里南,Suriname
+SJ=斯瓦尔巴和扬马延,Svalbard and Jan Mayen
+SE=瑞典,Sweden,ARN,斯德哥尔摩,Stockholm
+CH=瑞士,Switzerland,ZRH,GVA,苏黎世,日内瓦,Zurich,Geneva
+SY=叙利亚,Syria
+TJ=塔吉克斯坦,Tajikistan
+TZ=坦桑尼亚,Tanzania
+TL=东帝汶,Timor-Leste,East Timor
+TG=多哥,Togo
+TK=托克劳,Tokelau
+TO=汤加,Tonga
+TT=特立尼达和多巴哥,Trinidad and Tobago
+TN=突尼斯,Tunisia
+TM=土库曼斯坦,Turkmenistan
+TC=特克斯和凯科斯群岛,Turks and Caicos Islands
+TV=图瓦卢,Tuvalu
+UG=乌干达,Uganda
+UA=乌克兰,Ukraine,KBP,基辅,Kyiv,Kiev
+UM=美国本土外小岛屿,United States Minor Outlying Islands
+UY=乌拉圭,Uruguay,MVD,蒙得维的亚,Montevideo
+UZ=乌兹别克斯坦,Uzbekistan,TAS,塔什干,Tashkent
+VU=瓦努阿图,Vanuatu
+VE=委内瑞拉,Venezuela,CCS,加拉加斯,Caracas
+VG=英属维尔京群岛,British Virgin Islands
+VI=美属维尔京群岛,U.S. Virgin Islands
+WF=瓦利斯和富图纳,Wallis and Futuna
+EH=西撒哈拉,Western Sahara
+YE=也门,Yemen
+ZM=赞比亚,Zambia
+ZW=津巴布韦,Zimbabwe
+`
+
+// 这些写法常出现在节点名中但并不代表地区 (如线路类型 CN2、ASN 号)
+var regionNoise = regexp.MustCompile(`(?i)CN2|AS\d{3,}`)
+
+// regionTable 按代码保存全部地区，regionList 保持定义顺序
+var (
+	regionTable = map[string]*Region{}
+	regionList  []*Region
+)
+
+func init() {
+	for _, line := range strings.Split(builtinRegions, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if err := addRegion(line[:idx], line[idx+1:]); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// addRegion 新增地区，或给已有地区追加别名 (配置文件中的写法与内置表相同)
+func addRegion(code, spec string) error {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return fmt.Errorf("地区代码必须是两位字母: %q", code)
+	}
+	var fields []string
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+
+	r, ok := regionTable[code]
+	if !ok {
+		if len(fields) < 2 {
+			return fmt.Errorf("新地区 %s 至少需要中文名和英文名", code)
+		}
+		r = &Region{Code: code, Name: fields[0], EnName: fields[1]}
+		regionTable[code] = r
+		regionList = append(regionList, r)
+		fields = fields[2:]
+	}
+	r.Aliases = append(r.Aliases, fields...)
+	return nil
+}
+
+// applyRegionSettings 把配置文件 [regions] 中的条目合并进地区表
+func applyRegionSettings(entries []iniEntry) error {
+	for _, e := range entries {
+		if err := addRegion(e.Key, e.Value); err != nil {
+			return fmt.Errorf("第 %d 行: %v", e.Line, err)
+		}
+	}
+	return nil
+}
+
+// 匹配种类，数值越小越可信
+const (
+	matchFlag = iota
+	matchNative
+	matchEnglish
+	matchCode
+)
+
+type regionMatch struct {
+	region *Region
+	kind   int
+	pos    int
+	length int
+}
+
+func (m regionMatch) better(o regionMatch) bool {
+	if o.region == nil {
+		return true
+	}
+	if m.kind != o.kind {
+		return m.kind < o.kind
+	}
+	if m.length != o.length {
+		return m.length > o.length
+	}
+	return m.pos < o.pos
+}
+
+// 与节点名中常见写法冲突的 ISO 代码 (AI-01、TV、SS 协议、NO.1 等)，不单独按代码匹配，
+// 这些地区仍可通过中英文名、别名和国旗识别
+var ambiguousCodes = map[string]bool{
+	"AI": true, "AM": true, "AS": true, "AT": true, "BE": true, "BY": true, "CC": true, "DO": true,
+	"FM": true, "GG": true, "IS": true, "IT": true, "LA": true, "ME": true, "MS": true, "NO": true,
+	"PM": true, "PS": true, "SS": true, "ST": true, "TO": true, "TT": true, "TV": true,
+}
+
+// detectRegion 根据节点名识别地区代码，识别不出时返回空字符串。
+// 中转节点常写作 "上海-香港"、"广州→日本"，同时出现国内与境外地区时取境外 (出口) 地区
+func detectRegion(name string) string {
+	name = regionNoise.ReplaceAllString(name, " ")
+	lower := strings.ToLower(name)
+	var best, foreign regionMatch
+	consider := func(m regionMatch) {
+		if m.better(best) {
+			best = m
+		}
+		if m.region.Code != "CN" && m.better(foreign) {
+			foreign = m
+		}
+	}
+
+	// 国旗 emoji 最可信
+	runes := []rune(name)
+	for i := 0; i+1 < len(runes); i++ {
+		if isRegionalIndicator(runes[i]) && isRegionalIndicator(runes[i+1]) {
+			code := string([]rune{'A' + runes[i] - 0x1F1E6, 'A' + runes[i+1] - 0x1F1E6})
+			if r, ok := regionTable[code]; ok {
+				consider(regionMatch{region: r, kind: matchFlag, pos: i, length: 2})
+			}
+			i++
+		}
+	}
+
+	for _, r := range regionList {
+		terms := append([]string{r.Name, r.EnName, r.Code}, r.Aliases...)
+		for _, t := range terms {
+			var m regionMatch
+			switch {
+			case !isASCII(t):
+				pos := indexNative(name, t)
+				if pos < 0 {
+					continue
+				}
+				m = regionMatch{region: r, kind: matchNative, pos: pos, length: utf8.RuneCountInString(t)}
+			case len(t) <= 3 && strings.ToUpper(t) == t:
+				if t == r.Code && ambiguousCodes[t] {
+					continue
+				}
+				pos := indexToken(name, t, true)
+				if pos < 0 {
+					continue
+				}
+				m = regionMatch{region: r, kind: matchCode, pos: pos, length: len(t)}
+			default:
+				pos := indexToken(lower, strings.ToLower(t), false)
+				if pos < 0 {
+					continue
+				}
+				m = regionMatch{region: r, kind: matchEnglish, pos: pos, length: len(t)}
+			}
+			consider(m)
+		}
+	}
+	if foreign.region != nil {
+		return foreign.region.Code
+	}
+	if best.region == nil {
+		return ""
+	}
+	return best.region.Code
+}
+
+// indexToken 查找前后不紧挨英文字母的 token；code 为 true 时前面也不能是数字，
+// 这样 "HK01" 能识别出 HK，而 "Russia" 不会误识别为 US、"100GB" 不会识别为 GB
+func indexToken(s, token string, code bool) int {
+	from := 0
+	for {
+		idx := strings.Index(s[from:], token)
+		if idx < 0 {
+			return -1
+		}
+		start := from + idx
+		end := start + len(token)
+		okBefore := start == 0 || !isASCIILetter(s[start-1]) && !(code && s[start-1] >= '0' && s[start-1] <= '9')
+		okAfter := end == len(s) || !isASCIILetter(s[end])
+		if okBefore && okAfter {
+			return start
+		}
+		from = start + 1
+	}
+}
+
+// indexNative 按子串查找中文别名；单字别名要求前后不是汉字
+func indexNative(s, alias string) int {
+	if utf8.RuneCountInString(alias) != 1 {
+		return strings.Index(s, alias)
+	}
+	from := 0
+	for {
+		idx := strings.Index(s[from:], alias)
+		if idx < 0 {
+			return -1
+		}
+		start := from + idx
+		end := start + len(alias)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if !unicode.Is(unicode.Han, before) && !unicode.Is(unicode.Han, after) {
+			return start
+		}
+		from = end
+	}
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// 地区分组的优先排序，其余地区按节点数量降序
+var preferredRegions = []string{"HK", "TW", "JP", "SG", "US", "KR"}
+
+// infoRegion 标记流量信息占位节点，不参与地区分组
+const infoRegion = "-"
+
+func classifyNodes(nodes []Node) map[string][]Node {
+	groups := map[string][]Node{}
+	for _, n := range nodes {
+		if n.Region == infoRegion {
+			continue
+		}
+		code := n.Region
+		if code == "" {
+			code = detectRegion(n.Name)
+		}
+		if code == "" {
+			code = "Other"
+		}
+		groups[code] = append(groups[code], n)
+	}
+	return groups
+}
+
+// regionOrder 返回地区分组的输出顺序，Other 总在最后
+func regionOrder(groups map[string][]Node) []string {
+	rank := map[string]int{}
+	for i, c := range preferredRegions {
+		rank[c] = i + 1
+	}
+	var codes []string
+	for code, ns := range groups {
+		if code != "Other" && len(ns) > 0 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		ri, rj := rank[codes[i]], rank[codes[j]]
+		if ri != rj {
+			if ri == 0 || rj == 0 {
+				return rj == 0
+			}
+			return ri < rj
+		}
+		if len(groups[codes[i]]) != len(groups[codes[j]]) {
+			return len(groups[codes[i]]) > len(groups[codes[j]])
+		}
+		return codes[i] < codes[j]
+	})
+	if len(groups["Other"]) > 0 {
+		codes = append(codes, "Other")
+	}
+	return codes
+}
+
+func getCountryGroupName(code string) string {
+	r, ok := regionTable[code]
+	if !ok {
+		return "🏳️‍🌈 其他地区"
+	}
+	return r.Flag() + " " + r.Name + "节点"
+}
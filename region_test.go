@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestDetectRegion(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"🇭🇰 香港 01", "HK"},
+		{"HK01", "HK"},
+		{"Hong Kong IPLC", "HK"},
+		{"港 01", "HK"},
+		{"IPLC-港", "HK"},
+		{"港口专线", ""},
+		{"台湾 02", "TW"},
+		{"台-02", "TW"},
+		{"台式机", ""},
+		{"🇯🇵 Tokyo 03", "JP"},
+		{"日本 NRT", "JP"},
+		{"Russia 01", "RU"},
+		{"美国 100GB", "US"},
+		{"🇺🇸 香港中转", "US"},
+		{"新加坡 SIN", "SG"},
+		{"🦁 狮城", "SG"},
+		{"United Kingdom", "GB"},
+		{"上海-香港 IPLC", "HK"},
+		{"广州→日本 01", "JP"},
+		{"深圳 中转 🇸🇬", "SG"},
+		{"🇨🇳 上海 01", "CN"},
+		{"回国 北京", "CN"},
+		{"AI-01", ""},
+		{"TV 专线", ""},
+		{"SS 01", ""},
+		{"NO.1 美国", "US"},
+		{"Italy 01", "IT"},
+		{"剩余流量 100GB", ""},
+		{"1.2.3.4", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := detectRegion(tt.name); got != tt.want {
+			t.Errorf("detectRegion(%q) = %q，应为 %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// --- 规则解析 ---
+
+// Rule 是一条解析后的分流规则
+type Rule struct {
+	Type    string
+	Value   string   // MATCH 没有值；AND/OR/NOT 为括号内的子规则原文
+	Target  string   // 策略组，规则列表中的规则由调用方填入
+	Options []string // no-resolve、src 等附加参数，输出在策略组之后
+	From    string   // 来源 (规则列表名/自定义规则)，只用于统计，不输出
+}
+
+func (r Rule) String() string {
+	parts := []string{r.Type}
+	if r.Type != "MATCH" {
+		parts = append(parts, r.Value)
+	}
+	parts = append(parts, r.Target)
+	return strings.Join(append(parts, r.Options...), ",")
+}
+
+// IsIP 判断规则是否按 IP 匹配，只有这类规则可以带 no-resolve
+func (r Rule) IsIP() bool {
+	return ruleTypes[r.Type] == ruleIP
+}
+
+type ruleKind int
+
+const (
+	ruleDomain ruleKind = iota + 1
+	ruleIP
+	ruleOther
+)
+
+// Clash (mihomo) 支持的规则类型
+var ruleTypes = map[string]ruleKind{
+	"DOMAIN":             ruleDomain,
+	"DOMAIN-SUFFIX":      ruleDomain,
+	"DOMAIN-KEYWORD":     ruleDomain,
+	"DOMAIN-REGEX":       ruleDomain,
+	"GEOSITE":            ruleDomain,
+	"IP-CIDR":            ruleIP,
+	"IP-CIDR6":           ruleIP,
+	"IP-SUFFIX":          ruleIP,
+	"IP-ASN":             ruleIP,
+	"GEOIP":              ruleIP,
+	"SRC-GEOIP":          ruleOther,
+	"SRC-IP-ASN":         ruleOther,
+	"SRC-IP-CIDR":        ruleOther,
+	"SRC-IP-SUFFIX":      ruleOther,
+	"DST-PORT":           ruleOther,
+	"SRC-PORT":           ruleOther,
+	"IN-PORT":            ruleOther,
+	"IN-TYPE":            ruleOther,
+	"IN-USER":            ruleOther,
+	"IN-NAME":            ruleOther,
+	"PROCESS-NAME":       ruleOther,
+	"PROCESS-PATH":       ruleOther,
+	"PROCESS-NAME-REGEX": ruleOther,
+	"PROCESS-PATH-REGEX": ruleOther,
+	"UID":                ruleOther,
+	"NETWORK":            ruleOther,
+	"DSCP":               ruleOther,
+	"RULE-SET":           ruleOther,
+	"SUB-RULE":           ruleOther,
+	"AND":                ruleOther,
+	"OR":                 ruleOther,
+	"NOT":                ruleOther,
+	"MATCH":              ruleOther,
+}
+
+// Surge / Quantumult X 列表中的别名
+var ruleAliases = map[string]string{
+	"HOST":         "DOMAIN",
+	"HOST-SUFFIX":  "DOMAIN-SUFFIX",
+	"HOST-KEYWORD": "DOMAIN-KEYWORD",
+	"IP6-CIDR":     "IP-CIDR6",
+	"FINAL":        "MATCH",
+}
+
+// 其他客户端特有、Clash 无法识别的规则类型，解析时直接丢弃
+var unsupportedRuleTypes = map[string]bool{
+	"USER-AGENT":     true,
+	"URL-REGEX":      true,
+	"PROTOCOL":       true,
+	"SUBNET":         true,
+	"CELLULAR-RADIO": true,
+	"DEVICE-NAME":    true,
+}
+
+// errRuleUnsupported 表示规则合法但 Clash 不支持，调用方应静默跳过
+var errRuleUnsupported = errors.New("Clash 不支持的规则类型")
+
+// 各规则类型允许的附加参数
+var ruleOptions = map[string]bool{"no-resolve": true, "src": true}
+
+// parseRule 解析一行完整规则 TYPE,value,策略组[,参数]；
+// withTarget 为 false 时按规则列表格式解析，即没有策略组
+func parseRule(line string, withTarget bool) (Rule, error) {
+	fields := splitRule(line)
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	typ := strings.ToUpper(fields[0])
+	if a, ok := ruleAliases[typ]; ok {
+		typ = a
+	}
+	if unsupportedRuleTypes[typ] {
+		return Rule{}, errRuleUnsupported
+	}
+	if ruleTypes[typ] == 0 {
+		return Rule{}, fmt.Errorf("未知的规则类型 %q", fields[0])
+	}
+
+	r := Rule{Type: typ}
+	rest := fields[1:]
+	if typ != "MATCH" {
+		if len(rest) == 0 || rest[0] == "" {
+			return r, fmt.Errorf("%s 规则缺少匹配内容", typ)
+		}
+		r.Value, rest = rest[0], rest[1:]
+	}
+	if withTarget {
+		if len(rest) == 0 || rest[0] == "" {
+			return r, errors.New("规则缺少策略组")
+		}
+		r.Target, rest = rest[0], rest[1:]
+	}
+	for _, o := range rest {
+		if o == "" {
+			continue
+		}
+		if !ruleOptions[o] {
+			if withTarget {
+				return r, fmt.Errorf("未知的规则参数 %q", o)
+			}
+			continue // 列表里常见其他客户端的参数 (如 extended-matching)，忽略即可
+		}
+		if o == "no-resolve" && !r.IsIP() {
+			continue
+		}
+		r.addOption(o)
+	}
+
+	switch r.Type {
+	case "IP-CIDR", "IP-CIDR6", "SRC-IP-CIDR":
+		ip, _, err := net.ParseCIDR(r.Value)
+		if err != nil {
+			return r, fmt.Errorf("无效的 CIDR %q", r.Value)
+		}
+		// 列表常把 IPv6 写成 IP-CIDR，按地址族纠正
+		if r.Type != "SRC-IP-CIDR" {
+			if ip.To4() == nil {
+				r.Type = "IP-CIDR6"
+			} else {
+				r.Type = "IP-CIDR"
+			}
+		}
+	case "DOMAIN", "DOMAIN-SUFFIX":
+		r.Value = strings.ToLower(strings.TrimPrefix(r.Value, "."))
+	}
+	return r, nil
+}
+
+func (r *Rule) addOption(o string) {
+	for _, x := range r.Options {
+		if x == o {
+			return
+		}
+	}
+	r.Options = append(r.Options, o)
+}
+
+// splitRule 按逗号拆分规则，AND/OR/NOT 括号内的逗号不拆
+func splitRule(line string) []string {
+	var fields []string
+	depth, start := 0, 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				fields = append(fields, line[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, line[start:])
+}
+
+// parseRuleList 解析规则列表，支持经典 TYPE,value 行、YAML payload、
+// 以及 domain/ipcidr 行为的纯域名/纯 CIDR 列表；返回规则与跳过的行数
+func parseRuleList(content string, l RuleList) (rules []Rule, skipped int) {
+	yaml := l.Format == "yaml"
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if line == "payload:" {
+			yaml = true
+			continue
+		}
+		if yaml {
+			if !strings.HasPrefix(line, "-") {
+				continue
+			}
+			line = strings.Trim(strings.TrimSpace(line[1:]), `'"`)
+		}
+		if idx := strings.Index(line, " #"); idx > 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		if line == "" {
+			continue
+		}
+
+		var r Rule
+		var err error
+		switch {
+		case l.Behavior == "domain" || (l.Behavior != "ipcidr" && !strings.Contains(line, ",") && !isCIDR(line) && net.ParseIP(line) == nil):
+			r, err = parseDomainEntry(line, l.Behavior == "domain")
+		case l.Behavior == "ipcidr" || !strings.Contains(line, ","):
+			// 不带前缀长度的单个 IP 按 /32、/128 处理
+			if ip := net.ParseIP(line); ip != nil {
+				if ip.To4() != nil {
+					line += "/32"
+				} else {
+					line += "/128"
+				}
+			}
+			r, err = parseRule("IP-CIDR,"+line, false)
+			r.addOption("no-resolve")
+		default:
+			r, err = parseRule(line, false)
+		}
+		if err != nil {
+			skipped++
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, skipped
+}
+
+// parseDomainEntry 解析 domain 行为的条目：+.x / .x 为后缀，*.x 为单级通配；
+// exact 为 false 时 (经典列表里的裸域名) 按后缀处理，与旧版行为一致
+func parseDomainEntry(s string, exact bool) (Rule, error) {
+	s = strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(s, "+."):
+		return Rule{Type: "DOMAIN-SUFFIX", Value: s[2:]}, nil
+	case strings.HasPrefix(s, "."):
+		return Rule{Type: "DOMAIN-SUFFIX", Value: s[1:]}, nil
+	case strings.Contains(s, "*"):
+		re := strings.ReplaceAll(strings.ReplaceAll(s, ".", `\.`), "*", `[^.]+`)
+		return Rule{Type: "DOMAIN-REGEX", Value: "^" + re + "$"}, nil
+	case strings.ContainsAny(s, " ,/"):
+		return Rule{}, fmt.Errorf("无效的域名 %q", s)
+	case exact:
+		return Rule{Type: "DOMAIN", Value: s}, nil
+	}
+	return Rule{Type: "DOMAIN-SUFFIX", Value: s}, nil
+}
+
+func isCIDR(s string) bool {
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// --- 规则编译 ---
+
+// CompileStat 是一个规则来源在编译时被移除的条数
+type CompileStat struct {
+	From     string
+	Total    int
+	Dup      int // 与前面的规则完全相同
+	Shadowed int // 被前面更宽的后缀/关键字/CIDR 覆盖，永远不会命中
+}
+
+// compileRules 按 Clash 自上而下的匹配顺序去掉重复规则和被前面规则覆盖的规则。
+// 覆盖判断与策略组无关：前面的规则一定先命中，后面的规则不会生效
+func compileRules(rules []Rule) ([]Rule, []CompileStat) {
+	var stats []CompileStat
+	statIdx := map[string]int{}
+	stat := func(from string) *CompileStat {
+		i, ok := statIdx[from]
+		if !ok {
+			i = len(stats)
+			statIdx[from] = i
+			stats = append(stats, CompileStat{From: from})
+		}
+		return &stats[i]
+	}
+
+	seen := map[string]bool{}
+	suffixes := map[string]bool{}
+	var keywords []string
+	// 前缀 -> 前面同网段规则是否都带 no-resolve
+	cidrs := map[string]bool{}
+
+	out := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		st := stat(r.From)
+		st.Total++
+		key := r.Type + "," + r.Value + "," + strings.Join(r.Options, ",")
+		if seen[key] {
+			st.Dup++
+			continue
+		}
+		if shadowedRule(r, suffixes, keywords, cidrs) {
+			st.Shadowed++
+			continue
+		}
+		seen[key] = true
+		out = append(out, r)
+
+		switch r.Type {
+		case "DOMAIN-SUFFIX":
+			suffixes[r.Value] = true
+		case "DOMAIN-KEYWORD":
+			keywords = append(keywords, strings.ToLower(r.Value))
+		case "IP-CIDR", "IP-CIDR6":
+			// 带 src 的规则匹配来源地址，不会覆盖后面匹配目标地址的规则
+			if hasOption(r, "src") {
+				break
+			}
+			if k, ok := cidrKey(r.Value); ok {
+				nr := hasOption(r, "no-resolve")
+				if prev, ok := cidrs[k]; ok {
+					nr = nr && prev
+				}
+				cidrs[k] = nr
+			}
+		}
+	}
+	return out, stats
+}
+
+func shadowedRule(r Rule, suffixes map[string]bool, keywords []string, cidrs map[string]bool) bool {
+	switch r.Type {
+	case "DOMAIN", "DOMAIN-SUFFIX":
+		d := r.Value
+		for {
+			if suffixes[d] {
+				return true
+			}
+			i := strings.Index(d, ".")
+			if i < 0 {
+				break
+			}
+			d = d[i+1:]
+		}
+		for _, k := range keywords {
+			if strings.Contains(r.Value, k) {
+				return true
+			}
+		}
+	case "DOMAIN-KEYWORD":
+		v := strings.ToLower(r.Value)
+		for _, k := range keywords {
+			if strings.Contains(v, k) {
+				return true
+			}
+		}
+	case "IP-CIDR", "IP-CIDR6":
+		if hasOption(r, "src") {
+			return false
+		}
+		_, n, err := net.ParseCIDR(r.Value)
+		if err != nil {
+			return false
+		}
+		ones, bits := n.Mask.Size()
+		nr := hasOption(r, "no-resolve")
+		// 前面带 no-resolve 的规则不会匹配域名请求，只能覆盖同样带 no-resolve 的规则
+		for l := 0; l <= ones; l++ {
+			k := n.IP.Mask(net.CIDRMask(l, bits)).String() + "/" + fmt.Sprint(l)
+			if prevNR, ok := cidrs[k]; ok && (!prevNR || nr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func cidrKey(s string) (string, bool) {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return "", false
+	}
+	ones, _ := n.Mask.Size()
+	return n.IP.String() + "/" + fmt.Sprint(ones), true
+}
+
+func hasOption(r Rule, o string) bool {
+	for _, x := range r.Options {
+		if x == o {
+			return true
+		}
+	}
+	return false
+}
+
+// printCompileReport 输出各规则来源被移除的条数
+func printCompileReport(stats []CompileStat) {
+	dup, shadowed := 0, 0
+	for _, s := range stats {
+		dup += s.Dup
+		shadowed += s.Shadowed
+	}
+	if dup+shadowed == 0 {
+		return
+	}
+	fmt.Printf("🧹 规则优化：移除重复 %d 条、被前面规则覆盖 %d 条\n", dup, shadowed)
+	for _, s := range stats {
+		if s.Dup+s.Shadowed == 0 {
+			continue
+		}
+		fmt.Printf("   %-24s 共 %5d 条，重复 %5d，被覆盖 %5d\n", s.From, s.Total, s.Dup, s.Shadowed)
+	}
+}
+
+// --- 自定义规则 ---
+
+// CustomRule 是用户粘贴的一条自定义规则，Line 为粘贴内容中的行号
+type CustomRule struct {
+	Line int
+	Rule Rule
+}
+
+// Clash 内置策略，自定义规则可以直接使用
+var builtinPolicies = map[string]bool{"DIRECT": true, "REJECT": true, "REJECT-DROP": true, "PASS": true, "COMPATIBLE": true}
+
+// parseCustomRules 严格解析自定义规则，接受 "- RULE" 的 YAML 写法和裸规则，
+// 错误带行号返回，出错的行不会进入配置
+func parseCustomRules(lines []string) ([]CustomRule, []error) {
+	var rules []CustomRule
+	var errs []error
+	for i, line := range lines {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") || line == "rules:" {
+			continue
+		}
+		if strings.HasPrefix(line, "-") {
+			line = strings.TrimSpace(line[1:])
+		}
+		line = strings.Trim(line, `'"`)
+		r, err := parseRule(line, true)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("第 %d 行 %s: %v", i+1, line, err))
+			continue
+		}
+		r.From = "自定义规则"
+		rules = append(rules, CustomRule{Line: i + 1, Rule: r})
+	}
+	return rules, errs
+}
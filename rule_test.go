@@ -0,0 +1,143 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		kept     []string
+		dup      int
+		shadowed int
+	}{
+		{
+			name:  "完全相同的规则只保留第一条",
+			rules: []string{"DOMAIN,a.com", "DOMAIN,a.com"},
+			kept:  []string{"DOMAIN,a.com"},
+			dup:   1,
+		},
+		{
+			name:     "后缀覆盖子域名和自身",
+			rules:    []string{"DOMAIN-SUFFIX,example.com", "DOMAIN,www.example.com", "DOMAIN-SUFFIX,cdn.example.com", "DOMAIN,example.org"},
+			kept:     []string{"DOMAIN-SUFFIX,example.com", "DOMAIN,example.org"},
+			shadowed: 2,
+		},
+		{
+			name:  "后缀只按整段匹配",
+			rules: []string{"DOMAIN-SUFFIX,ample.com", "DOMAIN,example.com"},
+			kept:  []string{"DOMAIN-SUFFIX,ample.com", "DOMAIN,example.com"},
+		},
+		{
+			name:     "关键字覆盖域名和更长的关键字",
+			rules:    []string{"DOMAIN-KEYWORD,google", "DOMAIN,www.google.com", "DOMAIN-KEYWORD,googleapis", "DOMAIN,youtube.com"},
+			kept:     []string{"DOMAIN-KEYWORD,google", "DOMAIN,youtube.com"},
+			shadowed: 2,
+		},
+		{
+			name:     "更宽的网段覆盖子网",
+			rules:    []string{"IP-CIDR,10.0.0.0/8", "IP-CIDR,10.1.0.0/16", "IP-CIDR,192.168.0.0/16"},
+			kept:     []string{"IP-CIDR,10.0.0.0/8", "IP-CIDR,192.168.0.0/16"},
+			shadowed: 1,
+		},
+		{
+			name:  "no-resolve 网段不覆盖需要解析的规则",
+			rules: []string{"IP-CIDR,10.0.0.0/8,no-resolve", "IP-CIDR,10.1.0.0/16"},
+			kept:  []string{"IP-CIDR,10.0.0.0/8,no-resolve", "IP-CIDR,10.1.0.0/16"},
+		},
+		{
+			name:     "no-resolve 网段覆盖同样带 no-resolve 的子网",
+			rules:    []string{"IP-CIDR,10.0.0.0/8,no-resolve", "IP-CIDR,10.1.0.0/16,no-resolve"},
+			kept:     []string{"IP-CIDR,10.0.0.0/8,no-resolve"},
+			shadowed: 1,
+		},
+		{
+			name:  "src 网段不覆盖目标地址规则",
+			rules: []string{"IP-CIDR,10.0.0.0/8,src", "IP-CIDR,10.0.0.0/8", "IP-CIDR,10.1.0.0/16,src"},
+			kept:  []string{"IP-CIDR,10.0.0.0/8,src", "IP-CIDR,10.0.0.0/8", "IP-CIDR,10.1.0.0/16,src"},
+		},
+		{
+			name:  "不同类型的同值规则互不覆盖",
+			rules: []string{"GEOIP,CN", "DOMAIN,google.com", "DOMAIN-SUFFIX,cn", "DOMAIN-SUFFIX,google.com"},
+			kept:  []string{"GEOIP,CN", "DOMAIN,google.com", "DOMAIN-SUFFIX,cn", "DOMAIN-SUFFIX,google.com"},
+		},
+		{
+			name:     "IPv6 网段",
+			rules:    []string{"IP-CIDR6,2001:db8::/32", "IP-CIDR6,2001:db8:1::/48"},
+			kept:     []string{"IP-CIDR6,2001:db8::/32"},
+			shadowed: 1,
+		},
+	}
+	for _, tt := range tests {
+		var rules []Rule
+		for _, line := range tt.rules {
+			r, err := parseRule(line, false)
+			if err != nil {
+				t.Fatalf("%s: 解析 %q 失败: %v", tt.name, line, err)
+			}
+			r.From = "test.list"
+			rules = append(rules, r)
+		}
+		out, stats := compileRules(rules)
+		var kept []string
+		for _, r := range out {
+			kept = append(kept, strings.Join(append([]string{r.Type, r.Value}, r.Options...), ","))
+		}
+		if !reflect.DeepEqual(kept, tt.kept) {
+			t.Errorf("%s: 保留 %q，应为 %q", tt.name, kept, tt.kept)
+		}
+		if len(stats) != 1 || stats[0].Total != len(tt.rules) || stats[0].Dup != tt.dup || stats[0].Shadowed != tt.shadowed {
+			t.Errorf("%s: 统计 %+v，应为 重复 %d 覆盖 %d", tt.name, stats, tt.dup, tt.shadowed)
+		}
+	}
+}
+
+func TestParseRuleList(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    RuleList
+		content string
+		want    []string
+		skipped int
+	}{
+		{
+			name:    "经典列表",
+			list:    RuleList{Format: "text", Behavior: "classical"},
+			content: "\ufeff# 注释\nDOMAIN-SUFFIX,google.com\nIP-CIDR,1.0.0.0/8,no-resolve\nexample.com\nUSER-AGENT,foo\n",
+			want:    []string{"DOMAIN-SUFFIX,google.com", "IP-CIDR,1.0.0.0/8,no-resolve", "DOMAIN-SUFFIX,example.com"},
+			skipped: 1, // Clash 不支持 USER-AGENT
+		},
+		{
+			name:    "经典列表中的裸 IP 和裸 CIDR",
+			list:    RuleList{Format: "text", Behavior: "classical"},
+			content: "1.2.3.4\n2001:db8::1\n10.0.0.0/8\n",
+			want:    []string{"IP-CIDR,1.2.3.4/32,no-resolve", "IP-CIDR6,2001:db8::1/128,no-resolve", "IP-CIDR,10.0.0.0/8,no-resolve"},
+		},
+		{
+			name:    "domain 行为",
+			list:    RuleList{Format: "text", Behavior: "domain"},
+			content: "+.google.com\n.youtube.com\nwww.example.com\n*.cdn.com\n",
+			want:    []string{"DOMAIN-SUFFIX,google.com", "DOMAIN-SUFFIX,youtube.com", "DOMAIN,www.example.com", `DOMAIN-REGEX,^[^.]+\.cdn\.com$`},
+		},
+		{
+			name:    "ipcidr 行为的 YAML",
+			list:    RuleList{Format: "yaml", Behavior: "ipcidr"},
+			content: "payload:\n  - '1.1.1.1'\n  - '10.0.0.0/8'\n  - 'bad'\n",
+			want:    []string{"IP-CIDR,1.1.1.1/32,no-resolve", "IP-CIDR,10.0.0.0/8,no-resolve"},
+			skipped: 1,
+		},
+	}
+	for _, tt := range tests {
+		rules, skipped := parseRuleList(tt.content, tt.list)
+		var got []string
+		for _, r := range rules {
+			got = append(got, strings.Join(append([]string{r.Type, r.Value}, r.Options...), ","))
+		}
+		if !reflect.DeepEqual(got, tt.want) || skipped != tt.skipped {
+			t.Errorf("%s: 得到 %q (跳过 %d)，应为 %q (跳过 %d)", tt.name, got, skipped, tt.want, tt.skipped)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"crypto/sha1"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- 规则下载与缓存 ---
+
+// 内置的规则快照，网络与缓存都不可用时兜底；发布前用 -update-snapshot 刷新
+//
+//go:embed snapshot
+var snapshotFS embed.FS
+
+// RuleFetcher 负责下载规则列表，带磁盘缓存 (ETag/Last-Modified 校验) 与内置快照兜底
+type RuleFetcher struct {
+	CacheDir string        // 缓存目录，为空则不缓存
+	MaxAge   time.Duration // 缓存在此时间内直接使用，不发请求
+	Offline  bool          // 完全离线：只用缓存和内置快照
+	Mirrors  []string      // 依次尝试的镜像，见 mirrorURL
+	Log      io.Writer     // 规则状态输出，为 nil 时不输出
+	Results  []FetchResult // 最近一次 downloadRules 的结果
+
+	client *http.Client
+}
+
+func NewRuleFetcher(cacheDir string, maxAge time.Duration, offline bool) *RuleFetcher {
+	return &RuleFetcher{
+		CacheDir: cacheDir,
+		MaxAge:   maxAge,
+		Offline:  offline,
+		Mirrors:  []string{"direct", "jsdelivr"},
+		Log:      os.Stdout,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// cacheMeta 记录在缓存文件旁的 .json 中
+type cacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+func (f *RuleFetcher) cachePath(rawURL string) string {
+	sum := sha1.Sum([]byte(rawURL))
+	return filepath.Join(f.CacheDir, path.Base(rawURL)+"."+hex.EncodeToString(sum[:4]))
+}
+
+func (f *RuleFetcher) readCache(rawURL string) (string, cacheMeta, bool) {
+	var meta cacheMeta
+	if f.CacheDir == "" {
+		return "", meta, false
+	}
+	p := f.cachePath(rawURL)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", meta, false
+	}
+	if mb, err := os.ReadFile(p + ".json"); err == nil {
+		json.Unmarshal(mb, &meta)
+	}
+	return string(b), meta, true
+}
+
+func (f *RuleFetcher) writeCache(rawURL, content string, meta cacheMeta) {
+	if f.CacheDir == "" {
+		return
+	}
+	if err := os.MkdirAll(f.CacheDir, 0755); err != nil {
+		return
+	}
+	p := f.cachePath(rawURL)
+	if content != "" {
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			return
+		}
+	}
+	mb, _ := json.MarshalIndent(meta, "", "  ")
+	os.WriteFile(p+".json", mb, 0644)
+}
+
+// snapshotPath 是规则列表在快照目录中的相对路径：去掉协议后的 URL，
+// 如 raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/Apple.list
+func snapshotPath(rawURL string) string {
+	p := rawURL
+	if i := strings.Index(p, "://"); i >= 0 {
+		p = p[i+3:]
+	}
+	return p
+}
+
+// readSnapshot 读取内置快照
+func readSnapshot(rawURL string) (string, bool) {
+	b, err := snapshotFS.ReadFile("snapshot/" + snapshotPath(rawURL))
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// FetchResult 是单个规则列表的获取结果
+type FetchResult struct {
+	URL     string
+	Content string
+	Source  string   // 网络/缓存/过期缓存/内置快照，失败时为空
+	Mirror  string   // 实际成功的镜像
+	Errors  []string // 各镜像的失败原因
+}
+
+func (r FetchResult) OK() bool { return r.Source != "" }
+
+// Fetch 获取单个规则列表：新鲜缓存 → 各镜像依次下载 → 过期缓存 → 内置快照
+func (f *RuleFetcher) Fetch(rawURL string) FetchResult {
+	res := FetchResult{URL: rawURL}
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		b, err := os.ReadFile(rawURL)
+		if err != nil {
+			res.Errors = append(res.Errors, err.Error())
+		} else {
+			res.Content, res.Source = string(b), "本地文件"
+		}
+		return res
+	}
+	cached, meta, hasCache := f.readCache(rawURL)
+
+	if hasCache && (f.Offline || time.Since(meta.Fetched) < f.MaxAge) {
+		res.Content, res.Source = cached, "缓存"
+		return res
+	}
+	if !f.Offline {
+		mirrors := f.Mirrors
+		if len(mirrors) == 0 {
+			mirrors = []string{"direct"}
+		}
+		for _, m := range mirrors {
+			u, err := mirrorURL(m, rawURL)
+			if err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", m, err))
+				continue
+			}
+			content, newMeta, notModified, err := f.download(u, meta, hasCache)
+			if err != nil {
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", m, err))
+				continue
+			}
+			res.Mirror = m
+			if notModified {
+				meta.Fetched = time.Now()
+				f.writeCache(rawURL, "", meta)
+				res.Content, res.Source = cached, "缓存"
+				return res
+			}
+			newMeta.URL = rawURL
+			f.writeCache(rawURL, content, newMeta)
+			res.Content, res.Source = content, "网络"
+			return res
+		}
+	}
+
+	// 下载失败：过期缓存优先，其次内置快照
+	if hasCache {
+		res.Content, res.Source = cached, "过期缓存"
+	} else if s, ok := readSnapshot(rawURL); ok {
+		res.Content, res.Source = s, "内置快照"
+	} else if f.Offline {
+		res.Errors = append(res.Errors, "离线模式，没有缓存，内置快照中也没有")
+	} else {
+		res.Errors = append(res.Errors, "没有缓存，内置快照中也没有")
+	}
+	return res
+}
+
+func (f *RuleFetcher) download(u string, meta cacheMeta, conditional bool) (string, cacheMeta, bool, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return "", meta, false, err
+	}
+	if conditional {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", meta, false, shortNetError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return "", meta, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", meta, false, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", meta, false, err
+	}
+	if err := validateRuleList(string(b)); err != nil {
+		return "", meta, false, err
+	}
+	return string(b), cacheMeta{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Fetched:      time.Now(),
+	}, false, nil
+}
+
+// shortNetError 去掉 url.Error 中冗长的地址，只保留失败原因
+func shortNetError(err error) error {
+	var dnsErr *net.DNSError
+	var ue *url.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return errors.New("DNS 解析失败")
+	case errors.As(err, &ue) && ue.Timeout():
+		return errors.New("连接超时")
+	case errors.As(err, &ue):
+		return ue.Err
+	}
+	return err
+}
+
+// validateRuleList 粗略检查下载内容确实是规则列表，而不是错误页
+func validateRuleList(content string) error {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return errors.New("内容为空")
+	}
+	head := strings.ToLower(trimmed)
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	if strings.HasPrefix(head, "<") || strings.Contains(head, "<html") || strings.Contains(head, "<!doctype") {
+		return errors.New("返回的是 HTML 页面")
+	}
+	for _, line := range strings.Split(trimmed, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, ";") {
+			return nil
+		}
+	}
+	return errors.New("没有有效规则")
+}
+
+// mirrorURL 把 raw.githubusercontent.com 地址转换为镜像地址：
+//
+//	direct    原地址
+//	jsdelivr  https://cdn.jsdelivr.net/gh/用户/仓库@分支/路径
+//	含 {path} 的前缀  把 {path} 替换为 "用户/仓库/分支/路径"，适合本地 HTTP 服务器
+//	其他前缀  直接拼接在原地址前 (ghproxy 类镜像)
+func mirrorURL(mirror, rawURL string) (string, error) {
+	const rawHost = "https://raw.githubusercontent.com/"
+	switch {
+	case mirror == "" || mirror == "direct":
+		return rawURL, nil
+	case !strings.HasPrefix(rawURL, rawHost):
+		return "", errors.New("非 GitHub 地址，跳过镜像")
+	}
+	p := strings.TrimPrefix(rawURL, rawHost)
+	switch {
+	case mirror == "jsdelivr":
+		parts := strings.SplitN(p, "/", 4)
+		if len(parts) < 4 {
+			return "", errors.New("无法转换为 jsDelivr 地址")
+		}
+		return fmt.Sprintf("https://cdn.jsdelivr.net/gh/%s/%s@%s/%s", parts[0], parts[1], parts[2], parts[3]), nil
+	case strings.Contains(mirror, "{path}"):
+		return strings.ReplaceAll(mirror, "{path}", p), nil
+	default:
+		return strings.TrimRight(mirror, "/") + "/" + rawURL, nil
+	}
+}
+
+func (f *RuleFetcher) downloadRules(urls []string) map[string]string {
+	results := make([]FetchResult, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, urlStr string) {
+			defer wg.Done()
+			results[i] = f.Fetch(urlStr)
+		}(i, u)
+	}
+	wg.Wait()
+
+	res := make(map[string]string)
+	for _, r := range results {
+		if r.OK() {
+			res[r.URL] = r.Content
+		}
+	}
+	f.Results = results
+	f.printSummary()
+	return res
+}
+
+// printSummary 输出每个规则列表的来源与状态
+func (f *RuleFetcher) printSummary() {
+	if f.Log == nil {
+		return
+	}
+	failed := 0
+	fmt.Fprintln(f.Log, "📋 规则列表状态:")
+	for _, r := range f.Results {
+		name := path.Base(r.URL)
+		switch {
+		case !r.OK():
+			failed++
+			fmt.Fprintf(f.Log, "   ❌ %-22s 未包含 (%s)\n", name, strings.Join(r.Errors, "; "))
+		case r.Source == "网络" || r.Source == "缓存" || r.Source == "本地文件":
+			via := ""
+			if r.Mirror != "" && r.Mirror != "direct" {
+				via = " via " + r.Mirror
+			}
+			fmt.Fprintf(f.Log, "   ✅ %-22s %s%s, %d 行\n", name, r.Source, via, strings.Count(r.Content, "\n"))
+		default:
+			fmt.Fprintf(f.Log, "   ⚠️  %-22s %s, %d 行 (%s)\n", name, r.Source, strings.Count(r.Content, "\n"), strings.Join(r.Errors, "; "))
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(f.Log, "⚠️  %d 个规则列表获取失败，生成的配置缺少对应分流规则！\n", failed)
+	}
+}
+
+// updateSnapshot 把规则源的全部列表下载到 dir，供重新编译时嵌入
+func updateSnapshot(dir string, src *RuleSource) error {
+	f := NewRuleFetcher("", 0, false)
+	for _, u := range src.URLs() {
+		r := f.Fetch(u)
+		if r.Source != "网络" {
+			return fmt.Errorf("下载失败: %s (%s)", u, strings.Join(r.Errors, "; "))
+		}
+		p := filepath.Join(dir, filepath.FromSlash(snapshotPath(u)))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, []byte(r.Content), 0644); err != nil {
+			return err
+		}
+		fmt.Printf(" [快照] %s\n", snapshotPath(u))
+	}
+	return nil
+}
+
+// ruleProviderName 取 URL 文件名 (去掉扩展名) 作为 rule-provider 名称
+func ruleProviderName(rawURL string) string {
+	return strings.TrimSuffix(path.Base(rawURL), path.Ext(rawURL))
+}
+
+// ruleProviderPath 是 rule-provider 在 Clash 配置目录下的文件路径
+func ruleProviderPath(rawURL string) string {
+	return "./ruleset/" + path.Base(rawURL)
+}
+
+// saveRuleSets 把最近下载的规则列表写入 baseDir 下的 ruleset 目录，供 file 类型的 rule-providers 使用
+func (f *RuleFetcher) saveRuleSets(baseDir string) (int, error) {
+	n := 0
+	for _, r := range f.Results {
+		if !r.OK() {
+			continue
+		}
+		p := filepath.Join(baseDir, filepath.FromSlash(ruleProviderPath(r.URL)))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return n, err
+		}
+		if err := os.WriteFile(p, []byte(r.Content), 0644); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+// 内置快照必须包含全部 Url* 列表，否则离线或网络受阻时对应分流规则缺失；
+// 失败时在能访问 GitHub 的环境运行 go run . -update-snapshot snapshot 后重新编译
+func TestSnapshotCoversURLs(t *testing.T) {
+	urls := []string{
+		UrlLan, UrlBanAD, UrlBanProgramAD, UrlChinaDomain, UrlChinaIP, UrlProxyLite,
+		UrlApple, UrlMicrosoft, UrlGoogle, UrlTelegram, UrlNetflix, UrlMedia,
+		UrlSteamCN, UrlGames, UrlOneDrive, UrlDisney, UrlYouTube, UrlGoogleAll,
+	}
+	for _, u := range urls {
+		content, ok := readSnapshot(u)
+		if !ok {
+			t.Errorf("内置快照缺少 %s", snapshotPath(u))
+			continue
+		}
+		if err := validateRuleList(content); err != nil {
+			t.Errorf("内置快照 %s 无效: %v", snapshotPath(u), err)
+		}
+	}
+}
+
+func TestSnapshotPath(t *testing.T) {
+	got := snapshotPath("https://raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/ChinaIp.list")
+	if want := "raw.githubusercontent.com/ACL4SSR/ACL4SSR/master/Clash/ChinaIp.list"; got != want {
+		t.Errorf("snapshotPath = %q，应为 %q", got, want)
+	}
+}
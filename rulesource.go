@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- 规则源 ---
+
+// 规则角色：与具体规则源无关的分流用途，buildConfig 按角色把规则绑定到策略组
+const (
+	RoleLan          = "lan"         // 局域网/私有地址
+	RoleReject       = "reject"      // 广告拦截
+	RoleRejectPlus   = "reject-plus" // 更多去广告 (AdblockPlus)
+	RoleDirectDomain = "direct"      // 国内域名
+	RoleDirectIP     = "direct-ip"   // 国内 IP
+	RoleProxy        = "proxy"       // 需要代理的常见域名
+	RoleApple        = "apple"
+	RoleMicrosoft    = "microsoft"
+	RoleGoogle       = "google"
+	RoleTelegram     = "telegram"
+	RoleNetflix      = "netflix"
+	RoleMedia        = "media" // 国外媒体
+	RoleSteam        = "steam"
+	RoleGames        = "games"
+	RoleOneDrive     = "onedrive"
+	RoleDisney       = "disney"
+	RoleYouTube      = "youtube"
+	RoleGemini       = "gemini"
+	RoleGooglePlay   = "google-play"
+	RoleGoogleSearch = "google-search"
+	RoleGoogleAll    = "google-all" // 完整 Google 域名 (RoleGoogle 只含国内可直连部分)
+	RoleOpenAI       = "openai"
+	RoleClaude       = "claude"
+	RoleSpotify      = "spotify"
+	RoleTikTok       = "tiktok"
+	RoleBilibili     = "bilibili"
+	RoleGamePlatform = "game-platform" // Epic/Steam/主机平台的商店与联机
+)
+
+// RuleList 是一个可下载的规则列表
+type RuleList struct {
+	URL      string
+	Format   string // text 或 yaml (payload: 格式)
+	Behavior string // classical / domain / ipcidr
+}
+
+// RuleSource 定义一套规则：每个角色对应若干规则列表，缺少的角色会被跳过
+type RuleSource struct {
+	Name  string
+	Title string
+	Lists map[string][]RuleList
+}
+
+// URLs 返回该规则源用到的全部列表地址 (去重)
+func (s *RuleSource) URLs() []string {
+	seen := map[string]bool{}
+	var urls []string
+	roles := make([]string, 0, len(s.Lists))
+	for r := range s.Lists {
+		roles = append(roles, r)
+	}
+	sort.Strings(roles)
+	for _, r := range roles {
+		for _, l := range s.Lists[r] {
+			if !seen[l.URL] {
+				seen[l.URL] = true
+				urls = append(urls, l.URL)
+			}
+		}
+	}
+	return urls
+}
+
+func classical(urls ...string) []RuleList {
+	var ls []RuleList
+	for _, u := range urls {
+		ls = append(ls, RuleList{URL: u, Format: "text", Behavior: "classical"})
+	}
+	return ls
+}
+
+const (
+	loyalsoldierBase = "https://raw.githubusercontent.com/Loyalsoldier/clash-rules/release/"
+	blackmatrix7Base = "https://raw.githubusercontent.com/blackmatrix7/ios_rule_script/master/rule/Clash/"
+	dustinWinBase    = "https://raw.githubusercontent.com/DustinWin/ruleset_geodata/clash-ruleset/"
+)
+
+func loyalsoldier(name, behavior string) RuleList {
+	return RuleList{URL: loyalsoldierBase + name + ".txt", Format: "yaml", Behavior: behavior}
+}
+
+func blackmatrix7(names ...string) []RuleList {
+	var ls []RuleList
+	for _, n := range names {
+		ls = append(ls, RuleList{URL: blackmatrix7Base + n + "/" + n + ".list", Format: "text", Behavior: "classical"})
+	}
+	return ls
+}
+
+func dustinWin(name, behavior string) RuleList {
+	return RuleList{URL: dustinWinBase + name + ".list", Format: "text", Behavior: behavior}
+}
+
+// 内置规则源
+var ruleSources = []*RuleSource{
+	{
+		Name:  "acl4ssr",
+		Title: "ACL4SSR (默认)",
+		Lists: map[string][]RuleList{
+			RoleLan:          classical(UrlLan),
+			RoleReject:       classical(UrlBanAD),
+			RoleRejectPlus:   classical(UrlBanProgramAD),
+			RoleDirectDomain: classical(UrlChinaDomain),
+			RoleDirectIP:     classical(UrlChinaIP),
+			RoleProxy:        classical(UrlProxyLite),
+			RoleApple:        classical(UrlApple),
+			RoleMicrosoft:    classical(UrlMicrosoft),
+			RoleGoogle:       classical(UrlGoogle),
+			RoleTelegram:     classical(UrlTelegram),
+			RoleNetflix:      classical(UrlNetflix),
+			RoleMedia:        classical(UrlMedia),
+			RoleSteam:        classical(UrlSteamCN),
+			RoleGames:        classical(UrlGames),
+			RoleOneDrive:     classical(UrlOneDrive),
+			RoleDisney:       classical(UrlDisney),
+			RoleYouTube:      classical(UrlYouTube),
+			RoleGoogleAll:    classical(UrlGoogleAll),
+			// ACL4SSR 没有的细分列表借用 blackmatrix7
+			RoleGemini:       blackmatrix7("Gemini"),
+			RoleGooglePlay:   blackmatrix7("GooglePlay"),
+			RoleGoogleSearch: blackmatrix7("GoogleSearch"),
+			RoleOpenAI:       blackmatrix7("OpenAI"),
+			RoleClaude:       blackmatrix7("Claude"),
+			RoleSpotify:      blackmatrix7("Spotify"),
+			RoleTikTok:       blackmatrix7("TikTok"),
+			RoleBilibili:     blackmatrix7("BiliBili"),
+			RoleGamePlatform: blackmatrix7("Epic", "Steam", "PlayStation", "Nintendo", "Xbox"),
+		},
+	},
+	{
+		Name:  "loyalsoldier",
+		Title: "Loyalsoldier clash-rules",
+		Lists: map[string][]RuleList{
+			RoleLan:          {loyalsoldier("private", "domain"), loyalsoldier("lancidr", "ipcidr")},
+			RoleReject:       {loyalsoldier("reject", "domain")},
+			RoleDirectDomain: {loyalsoldier("applications", "classical"), loyalsoldier("direct", "domain")},
+			RoleDirectIP:     {loyalsoldier("cncidr", "ipcidr")},
+			RoleProxy:        {loyalsoldier("proxy", "domain"), loyalsoldier("gfw", "domain"), loyalsoldier("tld-not-cn", "domain")},
+			RoleApple:        {loyalsoldier("icloud", "domain"), loyalsoldier("apple", "domain")},
+			RoleGoogle:       {loyalsoldier("google", "domain")},
+			RoleTelegram:     {loyalsoldier("telegramcidr", "ipcidr")},
+		},
+	},
+	{
+		Name:  "blackmatrix7",
+		Title: "blackmatrix7 ios_rule_script",
+		Lists: map[string][]RuleList{
+			RoleLan:          blackmatrix7("Lan"),
+			RoleReject:       blackmatrix7("AdvertisingLite"),
+			RoleRejectPlus:   blackmatrix7("Advertising"),
+			RoleDirectDomain: blackmatrix7("China"),
+			RoleDirectIP:     blackmatrix7("ChinaIPs"),
+			RoleProxy:        blackmatrix7("Global"),
+			RoleApple:        blackmatrix7("Apple"),
+			RoleMicrosoft:    blackmatrix7("Microsoft"),
+			RoleGoogle:       blackmatrix7("Google"),
+			RoleTelegram:     blackmatrix7("Telegram"),
+			RoleNetflix:      blackmatrix7("Netflix"),
+			RoleMedia:        blackmatrix7("GlobalMedia"),
+			RoleSteam:        blackmatrix7("Steam"),
+			RoleGames:        blackmatrix7("Game"),
+			RoleOneDrive:     blackmatrix7("OneDrive"),
+			RoleDisney:       blackmatrix7("Disney"),
+			RoleYouTube:      blackmatrix7("YouTube"),
+			RoleGemini:       blackmatrix7("Gemini"),
+			RoleGooglePlay:   blackmatrix7("GooglePlay"),
+			RoleGoogleSearch: blackmatrix7("GoogleSearch"),
+			RoleGoogleAll:    blackmatrix7("Google"),
+			RoleOpenAI:       blackmatrix7("OpenAI"),
+			RoleClaude:       blackmatrix7("Claude"),
+			RoleSpotify:      blackmatrix7("Spotify"),
+			RoleTikTok:       blackmatrix7("TikTok"),
+			RoleBilibili:     blackmatrix7("BiliBili"),
+			RoleGamePlatform: blackmatrix7("Epic", "Steam", "PlayStation", "Nintendo", "Xbox"),
+		},
+	},
+	{
+		Name:  "dustinwin",
+		Title: "DustinWin ruleset_geodata",
+		Lists: map[string][]RuleList{
+			RoleLan:          {dustinWin("private", "domain"), dustinWin("privateip", "ipcidr")},
+			RoleReject:       {dustinWin("ads", "domain")},
+			RoleDirectDomain: {dustinWin("cn", "domain")},
+			RoleDirectIP:     {dustinWin("cnip", "ipcidr")},
+			RoleProxy:        {dustinWin("proxy", "domain")},
+			RoleApple:        {dustinWin("apple-cn", "domain")},
+			RoleMicrosoft:    {dustinWin("microsoft-cn", "domain")},
+			RoleGoogle:       {dustinWin("google-cn", "domain")},
+			RoleTelegram:     {dustinWin("telegramip", "ipcidr")},
+			RoleNetflix:      {dustinWin("netflix", "domain")},
+			RoleMedia:        {dustinWin("media", "domain")},
+			RoleGames:        {dustinWin("games", "domain")}, // games-cn 是国内直连的游戏，不能走代理组
+		},
+	},
+}
+
+// findRuleSource 按名称查找规则源，名称为空时返回 ACL4SSR
+func findRuleSource(name string) (*RuleSource, error) {
+	if name == "" {
+		return ruleSources[0], nil
+	}
+	var names []string
+	for _, s := range ruleSources {
+		if strings.EqualFold(s.Name, name) {
+			return s, nil
+		}
+		names = append(names, s.Name)
+	}
+	return nil, fmt.Errorf("未知的规则源 %q (可选: %s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- 订阅转换服务 ---
+
+// Server 以 HTTP 提供订阅转换，路由器/手机可直接用 URL 拉取配置：
+//
+//	GET /sub?url=订阅地址或链接[|...]&mode=6&target=clash&token=令牌[&filename=xx.yaml]
+//
+// 省略 url 时合并 [sources] 中配置的全部来源
+type Server struct {
+	Modes   []*ModeTemplate
+	Base    ModeConfig
+	Fetcher *RuleFetcher
+	GeoIP   *geoIP
+	Token   string
+	Sources []Source
+
+	mu sync.Mutex // RuleFetcher 记录每次下载结果，生成过程串行执行
+}
+
+const defaultListenAddr = "127.0.0.1:25500"
+
+// ListenAndServe 启动服务；监听非本机地址时必须设置令牌，避免被当作公开的订阅中转
+func (s *Server) ListenAndServe(addr string) error {
+	if addr == "" {
+		addr = defaultListenAddr
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("监听地址格式应为 主机:端口: %v", err)
+	}
+	if s.Token == "" && !isLoopback(host) {
+		return fmt.Errorf("监听 %s 时必须用 -token 或 [serve] token 设置访问令牌", addr)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sub", s.handleSub)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	fmt.Printf("🌐 订阅转换服务已启动: http://%s/sub?url=订阅地址&mode=%d", addr, defaultModeIndex(s.Modes))
+	if s.Token != "" {
+		fmt.Print("&token=令牌")
+	}
+	fmt.Println()
+	return srv.ListenAndServe()
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return true
+	}
+	token := r.URL.Query().Get("token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimPrefix(h, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+func (s *Server) handleSub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "只支持 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "token 无效", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	switch strings.ToLower(q.Get("target")) {
+	case "", "clash", "clashmeta", "clash-meta", "mihomo":
+	default:
+		http.Error(w, "target 只支持 clash", http.StatusBadRequest)
+		return
+	}
+	mode, err := findMode(s.Modes, q.Get("mode"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sources := s.Sources
+	if q.Get("url") != "" {
+		sources = nil
+		for _, v := range strings.Split(q.Get("url"), "|") {
+			v = strings.TrimSpace(v)
+			// url 参数只接受订阅地址和分享链接，不读取服务器上的文件
+			if v == "" || !strings.Contains(v, "://") {
+				continue
+			}
+			sources = append(sources, Source{Spec: v})
+		}
+	}
+	// 使用 proxy-providers 时节点由 Clash 自行下载，url 可以省略
+	providers := len(s.Base.ProxyProviders) > 0
+	if len(sources) == 0 && !providers {
+		http.Error(w, "缺少 url 参数", http.StatusBadRequest)
+		return
+	}
+
+	var nodes []Node
+	var subs []*Subscription
+	for _, src := range sources {
+		res, err := loadSource(src)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("读取 %s 失败: %v", src.Title(), err), http.StatusBadGateway)
+			return
+		}
+		nodes = append(nodes, res.Nodes...)
+		if res.Sub != nil {
+			subs = append(subs, res.Sub)
+		}
+	}
+	nodes, _, _ = dedupeNodes(nodes)
+	if len(nodes) == 0 && !providers {
+		http.Error(w, "没有解析到有效节点", http.StatusBadGateway)
+		return
+	}
+	if s.GeoIP != nil {
+		resolveRegions(nodes, s.GeoIP)
+	}
+
+	c := getModeConfig(s.Modes, mode, s.Base)
+	nodes = applyUserInfo(&c, nodes, subs)
+	s.mu.Lock()
+	cfg := buildConfig(nodes, c, nil, s.Fetcher)
+	s.mu.Unlock()
+	var errs []string
+	for _, i := range validateConfig(cfg) {
+		if i.Error {
+			errs = append(errs, i.String())
+		}
+	}
+	if len(errs) > 0 {
+		http.Error(w, "生成的配置有错误:\n"+strings.Join(errs, "\n"), http.StatusInternalServerError)
+		return
+	}
+	content := renderConfig(cfg)
+	fmt.Printf("[serve] %s 模式 %s，%d 个节点\n", r.RemoteAddr, c.Name, len(nodes))
+
+	filename := q.Get("filename")
+	if filename == "" {
+		filename = "config.yaml"
+	}
+	w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(filename))
+	w.Header().Set("Profile-Update-Interval", "24")
+	if h := forwardUserInfo(subs); h != "" {
+		w.Header().Set("Subscription-Userinfo", h)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(content))
+}
+
+// forwardUserInfo 生成返回给客户端的 subscription-userinfo：
+// 单个订阅原样转发，多个订阅合并流量、取最早到期时间
+func forwardUserInfo(subs []*Subscription) string {
+	var infos []UserInfo
+	raw := ""
+	for _, sub := range subs {
+		if sub.Info != nil {
+			infos = append(infos, *sub.Info)
+			raw = sub.UserInfo
+		}
+	}
+	switch len(infos) {
+	case 0:
+		return ""
+	case 1:
+		return raw
+	}
+	return mergeUserInfo(infos).Header()
+}
+
+// findMode 按序号、模板名或文件名查找模式，为空时使用默认模式
+func findMode(modes []*ModeTemplate, v string) (int, error) {
+	if v == "" {
+		return defaultModeIndex(modes), nil
+	}
+	if i, err := strconv.Atoi(v); err == nil {
+		if i < 0 || i >= len(modes) {
+			return 0, fmt.Errorf("mode 超出范围 0-%d", len(modes)-1)
+		}
+		return i, nil
+	}
+	for i, m := range modes {
+		if strings.EqualFold(m.Name, v) || strings.EqualFold(strings.TrimSuffix(m.File, ".ini"), v) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("未知的模式 %q", v)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- 可选服务分流 ---
+
+// Service 是一组可按需开启的服务分流：一个策略组及其对应的规则角色
+type Service struct {
+	Name  string // 开关名，用于 -services / [general] services
+	Title string
+	Group GroupTemplate
+	Roles []string
+}
+
+// 内置可选服务。AI 服务不支持香港/大陆，默认只列出常用的解锁地区
+var services = []*Service{
+	{
+		Name:  "ai",
+		Title: "AI 服务 (OpenAI / Claude / Gemini)",
+		Group: GroupTemplate{Name: "🤖 AI 服务", Type: "select", Members: []string{"!!REGION=US|JP|SG|TW|KR|GB", "[]🚀 节点选择"}},
+		Roles: []string{RoleOpenAI, RoleClaude, RoleGemini},
+	},
+	{
+		Name:  "disney",
+		Title: "Disney+",
+		Group: GroupTemplate{Name: "🐭 迪士尼+", Type: "select", Members: []string{"[]🚀 节点选择", "!!REGION=HK|SG|TW|JP|US", "[]🎯 全球直连"}},
+		Roles: []string{RoleDisney},
+	},
+	{
+		Name:  "spotify",
+		Title: "Spotify",
+		Group: GroupTemplate{Name: "🎶 Spotify", Type: "select", Members: []string{"[]🚀 节点选择", "[]🎯 全球直连"}},
+		Roles: []string{RoleSpotify},
+	},
+	{
+		Name:  "tiktok",
+		Title: "TikTok",
+		Group: GroupTemplate{Name: "🎵 TikTok", Type: "select", Members: []string{"!!REGION=US|JP|SG|TW|KR|GB", "[]🚀 节点选择"}},
+		Roles: []string{RoleTikTok},
+	},
+	{
+		Name:  "bilibili",
+		Title: "哔哩哔哩 (港澳台番剧/海外回国)",
+		Group: GroupTemplate{Name: "📺 哔哩哔哩", Type: "select", Members: []string{"[]🎯 全球直连", "!!REGION=CN|HK|TW"}},
+		Roles: []string{RoleBilibili},
+	},
+	{
+		Name:  "games",
+		Title: "游戏平台 (Epic / Steam / PlayStation / Nintendo / Xbox)",
+		Group: GroupTemplate{Name: "🕹️ 游戏平台", Type: "select", Members: []string{"[]🎯 全球直连", "[]🚀 节点选择"}},
+		Roles: []string{RoleGamePlatform},
+	},
+}
+
+// findServices 解析逗号分隔的服务开关，all 表示全部开启
+func findServices(names []string) ([]*Service, error) {
+	var out []*Service
+	for _, n := range names {
+		if strings.EqualFold(n, "all") {
+			return services, nil
+		}
+		found := false
+		for _, s := range services {
+			if strings.EqualFold(s.Name, n) {
+				out = append(out, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			var all []string
+			for _, s := range services {
+				all = append(all, s.Name)
+			}
+			return nil, fmt.Errorf("未知的服务 %q (可选: %s, all)", n, strings.Join(all, ", "))
+		}
+	}
+	return out, nil
+}
+
+// applyServices 把开启的服务并入模板：策略组放在第一个组之后 (模板已有同名组时沿用模板的)，
+// 规则放在局域网/广告规则之后、其他规则列表之前，保证优先命中
+func applyServices(t *ModeTemplate, svcs []*Service) ([]GroupTemplate, []RulesetTemplate) {
+	if len(svcs) == 0 {
+		return t.Groups, t.Rulesets
+	}
+	exists := map[string]bool{}
+	for _, g := range t.Groups {
+		exists[g.Name] = true
+	}
+	var groups []GroupTemplate
+	var rulesets []RulesetTemplate
+	for _, s := range svcs {
+		if !exists[s.Group.Name] {
+			g := s.Group
+			// 自定义模板里可能没有 🚀 节点选择 等组，去掉不存在的引用
+			// 至少保留一个直接引用，避免没有匹配节点时整个组被省略而规则失去目标
+			g.Members = nil
+			literal := false
+			for _, m := range s.Group.Members {
+				if strings.HasPrefix(m, "[]") {
+					if !exists[m[2:]] && !builtinPolicies[m[2:]] {
+						continue
+					}
+					literal = true
+				}
+				g.Members = append(g.Members, m)
+			}
+			if !literal {
+				g.Members = append(g.Members, "[]DIRECT")
+			}
+			groups = append(groups, g)
+			exists[g.Name] = true
+		}
+		for _, r := range s.Roles {
+			rulesets = append(rulesets, RulesetTemplate{Group: s.Group.Name, Source: "source:" + r})
+		}
+	}
+
+	pos := 0
+	for pos < len(t.Rulesets) {
+		_, role, inline := resolveRuleset(t.Rulesets[pos].Source, ruleSources[0])
+		if inline != "" || (role != RoleLan && role != RoleReject && role != RoleRejectPlus) {
+			break
+		}
+		pos++
+	}
+	merged := append([]RulesetTemplate(nil), t.Rulesets[:pos]...)
+	merged = append(merged, rulesets...)
+	merged = append(merged, t.Rulesets[pos:]...)
+	return mergeGroupTemplates(t.Groups, groups), merged
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- 配置文件 (INI) ---
+
+// iniFile 是一个保留顺序、允许重复键的简易 INI 解析结果
+type iniFile struct {
+	Sections []*iniSection
+}
+
+type iniSection struct {
+	Name    string
+	Entries []iniEntry
+}
+
+type iniEntry struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// parseINI 解析 INI 文本，以 ; 或 # 开头的行视为注释
+func parseINI(r io.Reader) (*iniFile, error) {
+	f := &iniFile{}
+	cur := &iniSection{}
+	f.Sections = append(f.Sections, cur)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			cur = &iniSection{Name: strings.TrimSpace(line[1 : len(line)-1])}
+			f.Sections = append(f.Sections, cur)
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("第 %d 行格式错误: %s", lineNo, line)
+		}
+		cur.Entries = append(cur.Entries, iniEntry{
+			Key:   strings.TrimSpace(line[:idx]),
+			Value: strings.TrimSpace(line[idx+1:]),
+			Line:  lineNo,
+		})
+	}
+	return f, scanner.Err()
+}
+
+// Section 返回同名的全部小节合并后的结果，不存在时返回空小节
+func (f *iniFile) Section(name string) *iniSection {
+	s := &iniSection{Name: name}
+	if f == nil {
+		return s
+	}
+	for _, sec := range f.Sections {
+		if strings.EqualFold(sec.Name, name) {
+			s.Entries = append(s.Entries, sec.Entries...)
+		}
+	}
+	return s
+}
+
+// Get 返回最后一次出现的键值
+func (s *iniSection) Get(key string) string {
+	v := ""
+	for _, e := range s.Entries {
+		if strings.EqualFold(e.Key, key) {
+			v = e.Value
+		}
+	}
+	return v
+}
+
+// GetAll 返回所有同名键的值，按出现顺序
+func (s *iniSection) GetAll(key string) []string {
+	var vals []string
+	for _, e := range s.Entries {
+		if strings.EqualFold(e.Key, key) {
+			vals = append(vals, e.Value)
+		}
+	}
+	return vals
+}
+
+// Settings 是用户配置文件 (默认 converter.ini) 的内容
+type Settings struct {
+	Path      string
+	Regions   []iniEntry // [regions] 自定义/扩展地区
+	GeoIP     string     // [general] geoip，MMDB 国家数据库路径
+	GeoIPName bool       // [general] geoip_priority=name，节点名优先于 GeoIP
+	Templates string     // [general] templates，自定义模式模板目录
+	Services  string     // [general] services，开启的可选服务分流
+	UserInfo  string     // [general] userinfo，订阅流量信息写入方式
+	Backups   int        // [general] backups，覆盖输出文件前保留的备份数量
+	Sources   []Source   // [sources] 标签=订阅地址/文件/链接，按顺序合并
+
+	SourceGroups   bool     // [general] source_groups，为每个来源生成选择组
+	ProxyProviders []string // [general] proxy_provider，可多行，完整配置改用 proxy-providers
+
+	Groups []GroupTemplate // [groups] 用户自定义策略组，写法同模板的 custom_proxy_group
+	Clash  []iniEntry      // [clash] 端口、DNS、TUN 等全局设置，由 buildGeneralSettings 解析
+
+	HealthCheck HealthCheck // [health_check] 测速组的统一参数
+
+	ServeListen string // [serve] listen，订阅转换服务监听地址
+	ServeToken  string // [serve] token，访问令牌
+
+	WatchInterval  time.Duration // [watch] interval，定时重新生成的间隔
+	WatchMode      string        // [watch] mode，序号、模板名或文件名
+	WatchOutput    string        // [watch] output，输出文件
+	WatchRulesFile string        // [watch] rules_file，自定义规则文件
+	WatchReload    bool          // [watch] reload，更新后推送到 Clash
+
+	Controller       string // [controller] url，默认取 [clash] external_controller
+	ControllerSecret string // [controller] secret，默认取 [clash] secret
+	Push             bool   // [controller] push，生成后推送到正在运行的 Clash
+
+	CacheDir    string        // [rules] cache_dir
+	CacheMaxAge time.Duration // [rules] cache_max_age
+	Offline     bool          // [rules] offline
+	Mirrors     []string      // [rules] mirror，可多行，按顺序尝试
+
+	RuleSource           string // [rules] source，规则源名称
+	RuleProviderType     string // [rules] provider，http/file 时输出 rule-providers
+	RuleProviderInterval int    // [rules] provider_interval
+	CustomRulePosition   string // [rules] custom_rules，before/after
+}
+
+// loadSettings 读取配置文件；文件不存在且 optional 为 true 时返回空配置
+func loadSettings(path string, optional bool) (*Settings, error) {
+	s := &Settings{Path: path, CacheDir: "rule_cache", CacheMaxAge: 12 * time.Hour, RuleProviderInterval: 86400, WatchInterval: 6 * time.Hour, Backups: defaultBackups}
+	fh, err := os.Open(path)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	defer fh.Close()
+
+	ini, err := parseINI(fh)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	s.Regions = ini.Section("regions").Entries
+	s.GeoIP = ini.Section("general").Get("geoip")
+	switch v := ini.Section("general").Get("geoip_priority"); v {
+	case "", "ip":
+	case "name":
+		s.GeoIPName = true
+	default:
+		return nil, fmt.Errorf("%s: geoip_priority 应为 ip 或 name", path)
+	}
+	s.Templates = ini.Section("general").Get("templates")
+	s.Services = ini.Section("general").Get("services")
+	s.UserInfo = ini.Section("general").Get("userinfo")
+	if v := ini.Section("general").Get("backups"); v != "" {
+		if s.Backups, err = strconv.Atoi(v); err != nil || s.Backups < 0 {
+			return nil, fmt.Errorf("%s: backups 必须是非负整数", path)
+		}
+	}
+	s.SourceGroups = ini.Section("general").Get("source_groups") == "true"
+	s.ProxyProviders = ini.Section("general").GetAll("proxy_provider")
+	for _, e := range ini.Section("sources").Entries {
+		if err := checkSourceLabel(e.Key); err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行: %v", path, e.Line, err)
+		}
+		s.Sources = append(s.Sources, Source{Label: e.Key, Spec: e.Value})
+	}
+	s.Clash = ini.Section("clash").Entries
+	s.ServeListen = ini.Section("serve").Get("listen")
+	s.ServeToken = ini.Section("serve").Get("token")
+
+	watch := ini.Section("watch")
+	if v := watch.Get("interval"); v != "" {
+		if s.WatchInterval, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("%s: [watch] interval 格式错误: %v", path, err)
+		}
+	}
+	s.WatchMode = watch.Get("mode")
+	s.WatchOutput = watch.Get("output")
+	s.WatchRulesFile = watch.Get("rules_file")
+	s.WatchReload = watch.Get("reload") == "true"
+	s.Controller = ini.Section("controller").Get("url")
+	s.ControllerSecret = ini.Section("controller").Get("secret")
+	s.Push = ini.Section("controller").Get("push") == "true"
+	if s.HealthCheck, err = parseHealthCheck(ini.Section("health_check")); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, e := range ini.Section("groups").Entries {
+		if e.Key != "custom_proxy_group" {
+			return nil, fmt.Errorf("%s 第 %d 行: [groups] 只支持 custom_proxy_group=", path, e.Line)
+		}
+		g, err := parseGroupTemplate(e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行: %v", path, e.Line, err)
+		}
+		s.Groups = append(s.Groups, g)
+	}
+
+	rules := ini.Section("rules")
+	if v := rules.Get("cache_dir"); v != "" {
+		s.CacheDir = v
+	}
+	if v := rules.Get("cache_max_age"); v != "" {
+		if s.CacheMaxAge, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("%s: cache_max_age 格式错误: %v", path, err)
+		}
+	}
+	s.Offline = rules.Get("offline") == "true"
+	s.RuleSource = rules.Get("source")
+	s.RuleProviderType = rules.Get("provider")
+	s.CustomRulePosition = rules.Get("custom_rules")
+	if v := rules.Get("provider_interval"); v != "" {
+		if s.RuleProviderInterval, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("%s: provider_interval 必须是整数秒", path)
+		}
+	}
+	for _, v := range rules.GetAll("mirror") {
+		s.Mirrors = append(s.Mirrors, splitList(v)...)
+	}
+	return s, nil
+}
+
+// parseHealthCheck 读取 [health_check]，lazy/strategy 等与模板的 !!参数 使用同一套校验
+func parseHealthCheck(sec *iniSection) (HealthCheck, error) {
+	var h HealthCheck
+	var opts GroupTemplate
+	for _, e := range sec.Entries {
+		var err error
+		switch strings.ToLower(e.Key) {
+		case "url":
+			h.URL = resolveTestURL(e.Value)
+		case "interval":
+			h.Interval, err = strconv.Atoi(e.Value)
+		case "timeout":
+			h.Timeout, err = strconv.Atoi(e.Value)
+		case "tolerance":
+			h.Tolerance, err = strconv.Atoi(e.Value)
+		case "lazy":
+			err = opts.setOption("lazy", e.Value)
+		case "max_failed_times":
+			err = opts.setOption("max-failed-times", e.Value)
+		case "strategy":
+			err = opts.setOption("strategy", e.Value)
+		default:
+			err = fmt.Errorf("未知的设置项")
+		}
+		if err != nil {
+			return h, fmt.Errorf("[health_check] 第 %d 行 %s: %v", e.Line, e.Key, err)
+		}
+	}
+	h.Lazy, h.MaxFailedTimes, h.Strategy = opts.Lazy, opts.MaxFailedTimes, opts.Strategy
+	return h, nil
+}
+
+// splitList 按逗号拆分并去掉空白项
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- 多来源合并 ---
+
+// Source 是一个节点来源：订阅地址、本地文件或分享链接，Label 非空时节点名加上 "标签 | " 前缀
+type Source struct {
+	Label string
+	Spec  string
+}
+
+// SourceResult 是读取一个来源的结果
+type SourceResult struct {
+	Source
+	Nodes   []Node
+	Sub     *Subscription // 来源是订阅地址时的下载结果 (含流量信息)
+	Results []LinkResult  // 逐行解析结果，行号相对于订阅/文件内容
+}
+
+// 标签会出现在节点名和策略组名里，不能包含 YAML/规则中有特殊含义的字符
+const sourceLabelChars = ",:#[]{}|&*!'\"%@`="
+
+func checkSourceLabel(label string) error {
+	if label == "" {
+		return fmt.Errorf("来源标签不能为空")
+	}
+	if strings.ContainsAny(label, sourceLabelChars) {
+		return fmt.Errorf("来源标签 %q 不能包含 %s", label, sourceLabelChars)
+	}
+	return nil
+}
+
+// parseSourceLine 识别一行输入是否是来源：标签=地址、订阅地址或存在的本地文件；
+// 单条不带标签的分享链接返回 false，由调用方按普通链接处理。
+// 带 = 填充的 base64 行 (如折行的最后一段 "Yg==") 不当作 标签=地址
+func parseSourceLine(line string) (Source, bool) {
+	if i := strings.Index(line, "="); i > 0 && !strings.Contains(line[:i], "://") && !base64Line.MatchString(line) {
+		label := strings.TrimSpace(line[:i])
+		if spec := strings.TrimSpace(line[i+1:]); spec != "" && checkSourceLabel(label) == nil {
+			return Source{Label: label, Spec: spec}, true
+		}
+	}
+	if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+		return Source{Spec: line}, true
+	}
+	if !strings.Contains(line, "://") {
+		if st, err := os.Stat(line); err == nil && !st.IsDir() {
+			return Source{Spec: line}, true
+		}
+	}
+	return Source{}, false
+}
+
+// Title 返回用于输出提示的来源名称，不暴露带令牌的订阅地址
+func (s Source) Title() string {
+	if s.Label != "" {
+		return s.Label
+	}
+	if strings.HasPrefix(s.Spec, "http://") || strings.HasPrefix(s.Spec, "https://") {
+		return (&Subscription{URL: s.Spec}).Host()
+	}
+	return filepath.Base(s.Spec)
+}
+
+// loadSource 读取一个来源的全部节点
+func loadSource(src Source) (*SourceResult, error) {
+	res := &SourceResult{Source: src}
+	var text string
+	switch {
+	case strings.HasPrefix(src.Spec, "http://") || strings.HasPrefix(src.Spec, "https://"):
+		sub, err := fetchSubscription(nil, src.Spec)
+		if err != nil {
+			return nil, err
+		}
+		res.Sub, text = sub, sub.Links
+	case strings.Contains(src.Spec, "://"):
+		text = src.Spec
+	default:
+		b, err := os.ReadFile(src.Spec)
+		if err != nil {
+			return nil, err
+		}
+		if text, err = decodeSubscription(string(b)); err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Base(src.Spec), err)
+		}
+	}
+	res.Results = parseLinks(text)
+	res.Nodes = acceptedNodes(res.Results)
+	for i := range res.Nodes {
+		labelNode(&res.Nodes[i], src.Label)
+	}
+	return res, nil
+}
+
+// labelNode 给节点加上来源标签和名称前缀。地区在加前缀前按原名识别，
+// 识别不出时记为 Other，避免标签中的文字 (如 "US VPS") 被误认成地区
+func labelNode(n *Node, label string) {
+	if label == "" {
+		return
+	}
+	if n.Region == "" {
+		if n.Region = detectRegion(n.Name); n.Region == "" {
+			n.Region = "Other"
+		}
+	}
+	n.Source = label
+	n.Name = label + " | " + n.Name
+}
+
+// nodeKey 用于跨来源去重：同一服务器、端口、协议和凭据视为同一个节点
+func nodeKey(n Node) string {
+	return strings.Join([]string{n.Type, strings.ToLower(n.Server), n.Port, n.UUID, n.Password, n.Cipher}, "|")
+}
+
+// dedupeNodes 去掉重复节点 (保留先出现的)，并给重名节点加序号，Clash 不允许节点重名
+func dedupeNodes(nodes []Node) (out []Node, removed, renamed int) {
+	seenKey := map[string]bool{}
+	seenName := map[string]bool{}
+	for _, n := range nodes {
+		if n.Region != infoRegion {
+			k := nodeKey(n)
+			if seenKey[k] {
+				removed++
+				continue
+			}
+			seenKey[k] = true
+		}
+		if seenName[n.Name] {
+			base := n.Name
+			for i := 2; seenName[n.Name]; i++ {
+				n.Name = fmt.Sprintf("%s %d", base, i)
+			}
+			renamed++
+		}
+		seenName[n.Name] = true
+		out = append(out, n)
+	}
+	return out, removed, renamed
+}
+
+// sourceGroupName 是来源分组的组名
+func sourceGroupName(label string) string {
+	return "📦 " + label
+}
+
+// 来源分组插在这个组之后，并在引用它的组里紧随其后
+const sourceGroupAnchor = "♻️ 自动选择"
+
+// sourceGroups 为每个带标签的来源生成一个手动选择组；只有一个来源时不生成
+func sourceGroups(nodes []Node) []ProxyGroup {
+	var labels []string
+	members := map[string][]string{}
+	sources := map[string]bool{}
+	for _, n := range nodes {
+		if n.Region == infoRegion {
+			continue
+		}
+		sources[n.Source] = true
+		if n.Source == "" {
+			continue
+		}
+		if members[n.Source] == nil {
+			labels = append(labels, n.Source)
+		}
+		members[n.Source] = append(members[n.Source], n.Name)
+	}
+	if len(labels) == 0 || len(sources) < 2 {
+		return nil
+	}
+	var out []ProxyGroup
+	for _, l := range labels {
+		out = append(out, ProxyGroup{Name: sourceGroupName(l), Type: "select", Proxies: members[l]})
+	}
+	return out
+}
+
+// addSourceGroups 把来源分组插到 ♻️ 自动选择 之后，与已有组重名的跳过
+func addSourceGroups(groups []ProxyGroup, add []ProxyGroup) []ProxyGroup {
+	exists := map[string]bool{}
+	anchor := -1
+	for i, g := range groups {
+		exists[g.Name] = true
+		if g.Name == sourceGroupAnchor {
+			anchor = i
+		}
+	}
+	var added []ProxyGroup
+	var names []string
+	for _, g := range add {
+		if exists[g.Name] {
+			continue
+		}
+		added = append(added, g)
+		names = append(names, g.Name)
+	}
+	if len(added) == 0 {
+		return groups
+	}
+
+	// 引用锚点组的地方紧随其后加入来源分组；模板没有 ♻️ 自动选择 时放在第一个组的最前面
+	referenced := false
+	for i := range groups {
+		for j, p := range groups[i].Proxies {
+			if p == sourceGroupAnchor {
+				groups[i].Proxies = insertStrings(groups[i].Proxies, j+1, names)
+				referenced = true
+				break
+			}
+		}
+	}
+	if !referenced && len(groups) > 0 {
+		groups[0].Proxies = insertStrings(groups[0].Proxies, 0, names)
+	}
+
+	pos := anchor + 1
+	if anchor < 0 {
+		pos = 1
+	}
+	if pos > len(groups) {
+		pos = len(groups)
+	}
+	out := append([]ProxyGroup(nil), groups[:pos]...)
+	out = append(out, added...)
+	return append(out, groups[pos:]...)
+}
+
+func insertStrings(s []string, i int, v []string) []string {
+	out := append([]string(nil), s[:i]...)
+	out = append(out, v...)
+	return append(out, s[i:]...)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSourceLine(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "nodes.txt")
+	if err := os.WriteFile(file, []byte("ss://x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string
+		want Source
+		ok   bool
+	}{
+		{"机场A=https://sub.example.com/api?token=abc", Source{Label: "机场A", Spec: "https://sub.example.com/api?token=abc"}, true},
+		{" 家里 = " + file, Source{Label: "家里", Spec: file}, true},
+		{"https://sub.example.com/api?token=abc", Source{Spec: "https://sub.example.com/api?token=abc"}, true},
+		{file, Source{Spec: file}, true},
+		// 链接里的 = 不是标签分隔符
+		{"ss://YWVzLTEyOC1nY206cGFzcw==@1.2.3.4:8388#hk", Source{}, false},
+		{"vless://uuid@1.2.3.4:443?security=reality#jp", Source{}, false},
+		// 带填充的 base64 行不是 标签=地址
+		{"Yg==", Source{}, false},
+		{"dmxlc3M6Ly91dWlkQDEuMi4zLjQ6NDQzI2hr=", Source{}, false},
+		{"a:b=https://x", Source{}, false},
+		{"=https://x", Source{}, false},
+		{"机场A=", Source{}, false},
+		{filepath.Join(t.TempDir(), "missing.txt"), Source{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSourceLine(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSourceLine(%q) = %+v, %v，应为 %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDedupeNodes(t *testing.T) {
+	nodes := []Node{
+		{Type: "ss", Name: "香港", Server: "a.example.com", Port: "8388", Password: "p", Cipher: "aes-128-gcm"},
+		{Type: "ss", Name: "香港 备用", Server: "A.example.com", Port: "8388", Password: "p", Cipher: "aes-128-gcm"},
+		{Type: "ss", Name: "香港", Server: "b.example.com", Port: "8388", Password: "p", Cipher: "aes-128-gcm"},
+		{Type: "ss", Name: "香港", Server: "c.example.com", Port: "8388", Password: "p", Cipher: "aes-128-gcm"},
+		{Type: "ss", Name: "香港 2", Server: "d.example.com", Port: "8388", Password: "p", Cipher: "aes-128-gcm"},
+		// 流量信息节点服务器相同，不按服务器去重，只处理重名
+		{Type: "ss", Name: "剩余流量", Server: "127.0.0.1", Port: "1", Region: infoRegion},
+		{Type: "ss", Name: "到期时间", Server: "127.0.0.1", Port: "1", Region: infoRegion},
+		{Type: "ss", Name: "剩余流量", Server: "127.0.0.1", Port: "1", Region: infoRegion},
+	}
+	out, removed, renamed := dedupeNodes(nodes)
+	var names []string
+	for _, n := range out {
+		names = append(names, n.Name)
+	}
+	want := []string{"香港", "香港 2", "香港 3", "香港 2 2", "剩余流量", "到期时间", "剩余流量 2"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("节点 %q，应为 %q", names, want)
+	}
+	if removed != 1 || renamed != 4 {
+		t.Errorf("去重 %d 重命名 %d，应为 1 和 4", removed, renamed)
+	}
+}
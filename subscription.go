@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- 订阅下载 ---
+
+// Subscription 是下载并解码后的订阅内容
+type Subscription struct {
+	URL      string
+	Links    string    // 每行一个分享链接
+	UserInfo string    // 机场返回的 subscription-userinfo 头，原样保留
+	Info     *UserInfo // 解析后的流量信息，没有该头时为 nil
+}
+
+// Host 返回订阅地址的主机名，输出时不暴露带令牌的完整地址
+func (s *Subscription) Host() string {
+	if u, err := url.Parse(s.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return s.URL
+}
+
+var subscriptionClient = &http.Client{Timeout: 30 * time.Second}
+
+// 机场通常按 User-Agent 决定返回格式，使用 v2rayN 的 UA 以拿到通用的分享链接列表
+const subscriptionUA = "v2rayN/6.42"
+
+// fetchSubscription 下载订阅，client 为 nil 时使用默认客户端；只允许 http/https 地址
+func fetchSubscription(client *http.Client, rawURL string) (*Subscription, error) {
+	if client == nil {
+		client = subscriptionClient
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的订阅地址")
+	}
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", subscriptionUA)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", u.Host, shortNetError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", u.Host, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", u.Host, err)
+	}
+	links, err := decodeSubscription(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", u.Host, err)
+	}
+	sub := &Subscription{URL: rawURL, Links: links, UserInfo: resp.Header.Get("Subscription-Userinfo")}
+	if u, ok := parseUserInfo(sub.UserInfo); ok {
+		sub.Info = &u
+	}
+	return sub, nil
+}
+
+// decodeSubscription 识别订阅正文：整体 base64 编码的链接列表或明文链接列表
+func decodeSubscription(body string) (string, error) {
+	body = strings.TrimSpace(invisibleChars.Replace(body))
+	if body == "" {
+		return "", fmt.Errorf("订阅内容为空")
+	}
+	// Clash 配置里常有健康检查、rule-providers 等 URL，要先于链接列表判断
+	if strings.HasPrefix(body, "proxies:") || strings.Contains(body, "\nproxies:") {
+		return "", fmt.Errorf("订阅返回的是 Clash 配置而不是节点链接，请使用通用订阅地址")
+	}
+	if strings.Contains(body, "://") {
+		return body, nil
+	}
+	decoded, err := decodeBase64(strings.Join(strings.Fields(body), ""))
+	if err != nil || !strings.Contains(decoded, "://") {
+		return "", fmt.Errorf("无法识别的订阅格式")
+	}
+	return decoded, nil
+}
+
+// --- 流量信息 (subscription-userinfo) ---
+
+// UserInfo 是 subscription-userinfo 头中的流量与到期信息，字节/Unix 时间戳
+type UserInfo struct {
+	Upload   int64
+	Download int64
+	Total    int64
+	Expire   int64
+}
+
+// parseUserInfo 解析 upload=1; download=2; total=3; expire=4，缺项记为 0
+func parseUserInfo(h string) (UserInfo, bool) {
+	var u UserInfo
+	found := false
+	for _, part := range strings.Split(h, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "upload":
+			u.Upload = int64(v)
+		case "download":
+			u.Download = int64(v)
+		case "total":
+			u.Total = int64(v)
+		case "expire":
+			u.Expire = int64(v)
+		default:
+			continue
+		}
+		found = true
+	}
+	return u, found
+}
+
+func (u UserInfo) Used() int64 { return u.Upload + u.Download }
+
+// Remaining 返回剩余流量，总量未知时返回 -1
+func (u UserInfo) Remaining() int64 {
+	if u.Total <= 0 {
+		return -1
+	}
+	if r := u.Total - u.Used(); r > 0 {
+		return r
+	}
+	return 0
+}
+
+// Header 还原为 subscription-userinfo 头的格式
+func (u UserInfo) Header() string {
+	s := fmt.Sprintf("upload=%d; download=%d; total=%d", u.Upload, u.Download, u.Total)
+	if u.Expire > 0 {
+		s += fmt.Sprintf("; expire=%d", u.Expire)
+	}
+	return s
+}
+
+// Summary 生成一行可读的摘要，如 已用 12.3 GB / 200 GB，剩余 187.7 GB，2026-12-31 到期
+func (u UserInfo) Summary() string {
+	parts := []string{"已用 " + formatBytes(u.Used())}
+	if u.Total > 0 {
+		parts[0] += " / " + formatBytes(u.Total)
+		parts = append(parts, "剩余 "+formatBytes(u.Remaining()))
+	}
+	parts = append(parts, u.ExpireText())
+	return strings.Join(parts, "，")
+}
+
+func (u UserInfo) ExpireText() string {
+	if u.Expire <= 0 {
+		return "长期有效"
+	}
+	t := time.Unix(u.Expire, 0)
+	s := t.Format("2006-01-02") + " 到期"
+	if d := time.Until(t); d < 0 {
+		s += " (已过期)"
+	} else if d < 7*24*time.Hour {
+		s += fmt.Sprintf(" (剩 %d 天)", int(d.Hours()/24))
+	}
+	return s
+}
+
+// mergeUserInfo 合并多个订阅的流量信息：流量相加，到期取最早的一个
+func mergeUserInfo(infos []UserInfo) UserInfo {
+	var m UserInfo
+	for _, u := range infos {
+		m.Upload += u.Upload
+		m.Download += u.Download
+		m.Total += u.Total
+		if u.Expire > 0 && (m.Expire == 0 || u.Expire < m.Expire) {
+			m.Expire = u.Expire
+		}
+	}
+	return m
+}
+
+func formatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	v := float64(n)
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.2f %s", v, units[i])
+}
+
+// userInfoComments 生成写在配置文件开头的注释
+func userInfoComments(subs []*Subscription) []string {
+	var lines []string
+	for _, s := range subs {
+		if s.Info != nil {
+			lines = append(lines, fmt.Sprintf("%s: %s", s.Host(), s.Info.Summary()))
+		}
+	}
+	return lines
+}
+
+// userInfoNodes 生成只用于展示流量信息的占位节点 (连接不可用)，
+// 便于在不显示注释的客户端里查看剩余流量和到期时间
+func userInfoNodes(subs []*Subscription) []Node {
+	var infos []UserInfo
+	for _, s := range subs {
+		if s.Info != nil {
+			infos = append(infos, *s.Info)
+		}
+	}
+	if len(infos) == 0 {
+		return nil
+	}
+	u := mergeUserInfo(infos)
+	var names []string
+	if r := u.Remaining(); r >= 0 {
+		names = append(names, "剩余流量 "+formatBytes(r))
+	} else {
+		names = append(names, "已用流量 "+formatBytes(u.Used()))
+	}
+	names = append(names, u.ExpireText())
+	var nodes []Node
+	for _, n := range names {
+		nodes = append(nodes, Node{Type: "ss", Name: "ℹ️ " + n, Server: "127.0.0.1", Port: "1", Cipher: "aes-128-gcm", Password: "info", Region: infoRegion})
+	}
+	return nodes
+}
+
+// applyUserInfo 按 c.UserInfo 把订阅流量信息写成文件开头的注释和/或占位节点，返回新的节点列表
+func applyUserInfo(c *ModeConfig, nodes []Node, subs []*Subscription) []Node {
+	switch c.UserInfo {
+	case "off":
+		return nodes
+	case "node", "both":
+		nodes = append(userInfoNodes(subs), nodes...)
+	}
+	if c.UserInfo != "node" {
+		c.Comments = append(c.Comments, userInfoComments(subs)...)
+	}
+	return nodes
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDecodeSubscription(t *testing.T) {
+	links := "vless://id@1.2.3.4:443#a\nss://YWVzLTEyOC1nY206cHc@5.6.7.8:8388#b"
+	std := base64.StdEncoding.EncodeToString([]byte(links))
+	urlSafe := base64.RawURLEncoding.EncodeToString([]byte(links))
+	var wrapped []string
+	for i := 0; i < len(std); i += 20 {
+		end := i + 20
+		if end > len(std) {
+			end = len(std)
+		}
+		wrapped = append(wrapped, std[i:end])
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+		err  string
+	}{
+		{"明文链接", links, links, ""},
+		{"明文带 BOM 和空行", "\ufeff\n" + links + "\n\n", links, ""},
+		{"标准 base64", std, links, ""},
+		{"URL 安全且无填充的 base64", urlSafe, links, ""},
+		{"折行的 base64", strings.Join(wrapped, "\r\n"), links, ""},
+		{"空内容", "  \n", "", "订阅内容为空"},
+		{"Clash 配置", "mixed-port: 7890\nproxies:\n  - {name: a, type: ss}\nproxy-groups:\n  - {name: auto, type: url-test, url: http://www.gstatic.com/generate_204}\n", "", "Clash 配置"},
+		{"以 proxies 开头的 Clash 配置", "proxies:\n  - {name: a, type: ss}\nrule-providers:\n  r: {url: https://example.com/r.yaml}\n", "", "Clash 配置"},
+		{"无法识别", "hello world", "", "无法识别"},
+		{"base64 解码后不是链接", base64.StdEncoding.EncodeToString([]byte("just text")), "", "无法识别"},
+	}
+	for _, tt := range tests {
+		got, err := decodeSubscription(tt.body)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: 错误 %v，应包含 %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: 得到 %q，应为 %q", tt.name, got, tt.want)
+		}
+	}
+}
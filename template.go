@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- 模式模板 ---
+
+// 内置模式模板，格式兼容 subconverter 的 ACL4SSR .ini：
+//
+//	ruleset=策略组,规则列表URL        也支持 []GEOIP,CN、[]FINAL 等内联规则
+//	custom_proxy_group=名称`类型`成员1`成员2...[`测速URL`间隔,超时,容差]
+//
+// 成员写法：[]组名/DIRECT/REJECT 直接引用，其余按正则匹配节点名；
+// 扩展写法 !!REGIONS 展开为多国分组，!!REGION=HK|TW 只选这些地区的节点；
+// 测速组可用 !!lazy=true、!!max-failed-times=3、!!strategy=round-robin (负载均衡) 调整健康检查。
+//
+//go:embed templates/*.ini
+var templateFS embed.FS
+
+// GroupTemplate 是模板中的一个 custom_proxy_group
+type GroupTemplate struct {
+	Name      string
+	Type      string
+	Members   []string
+	URL       string
+	Interval  int
+	Timeout   int
+	Tolerance int
+
+	Lazy           *bool  // 为 nil 时不输出，沿用 Clash 默认值
+	MaxFailedTimes int    // 连续失败多少次后判定节点不可用
+	Strategy       string // load-balance 的 consistent-hashing / round-robin / sticky-sessions
+}
+
+// RulesetTemplate 是模板中的一个 ruleset
+type RulesetTemplate struct {
+	Group  string
+	Source string // 规则列表 URL / 本地路径、source:角色，或以 [] 开头的内联规则
+}
+
+// ModeTemplate 是一个完整的模式定义
+type ModeTemplate struct {
+	File         string
+	Name         string
+	Desc         string
+	Default      bool
+	ProviderOnly bool // 只输出 proxies (ShellClash Provider)
+	Groups       []GroupTemplate
+	Rulesets     []RulesetTemplate
+}
+
+func parseModeTemplate(file string, data []byte) (*ModeTemplate, error) {
+	ini, err := parseINI(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	sec := ini.Section("custom")
+	t := &ModeTemplate{
+		File:         file,
+		Name:         sec.Get("mode_name"),
+		Desc:         sec.Get("mode_desc"),
+		Default:      sec.Get("mode_default") == "true",
+		ProviderOnly: sec.Get("provider_only") == "true",
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if t.Desc == "" {
+		t.Desc = t.Name
+	}
+
+	for _, e := range sec.Entries {
+		switch e.Key {
+		case "ruleset":
+			idx := strings.Index(e.Value, ",")
+			if idx <= 0 {
+				return nil, fmt.Errorf("%s 第 %d 行: ruleset 格式应为 策略组,规则", file, e.Line)
+			}
+			t.Rulesets = append(t.Rulesets, RulesetTemplate{
+				Group:  strings.TrimSpace(e.Value[:idx]),
+				Source: strings.TrimSpace(e.Value[idx+1:]),
+			})
+		case "custom_proxy_group":
+			g, err := parseGroupTemplate(e.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s 第 %d 行: %v", file, e.Line, err)
+			}
+			t.Groups = append(t.Groups, g)
+		}
+	}
+	if !t.ProviderOnly && len(t.Groups) == 0 {
+		return nil, fmt.Errorf("%s: 没有定义任何 custom_proxy_group", file)
+	}
+	return t, nil
+}
+
+// parseGroupTemplate 解析 subconverter 的 custom_proxy_group 写法
+func parseGroupTemplate(spec string) (GroupTemplate, error) {
+	fields := strings.Split(spec, "`")
+	if len(fields) < 3 {
+		return GroupTemplate{}, fmt.Errorf("custom_proxy_group 至少需要 名称`类型`成员: %s", spec)
+	}
+	g := GroupTemplate{Name: strings.TrimSpace(fields[0]), Type: strings.TrimSpace(fields[1])}
+	rest := fields[2:]
+	switch g.Type {
+	case "select":
+	case "url-test", "fallback", "load-balance":
+		if n := len(rest); n >= 2 && strings.HasPrefix(rest[n-2], "http") {
+			g.URL = rest[n-2]
+			parts := strings.Split(rest[n-1], ",")
+			vals := make([]int, 3)
+			for i := 0; i < len(parts) && i < 3; i++ {
+				if p := strings.TrimSpace(parts[i]); p != "" {
+					v, err := strconv.Atoi(p)
+					if err != nil {
+						return g, fmt.Errorf("测速参数应为 间隔,超时,容差: %s", rest[n-1])
+					}
+					vals[i] = v
+				}
+			}
+			g.Interval, g.Timeout, g.Tolerance = vals[0], vals[1], vals[2]
+			rest = rest[:n-2]
+		}
+	default:
+		return g, fmt.Errorf("不支持的策略组类型 %q", g.Type)
+	}
+	for _, m := range rest {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		if opt, ok := groupOption(m); ok {
+			if err := g.setOption(opt, m[len(opt)+3:]); err != nil {
+				return g, err
+			}
+			continue
+		}
+		g.Members = append(g.Members, m)
+	}
+	if len(g.Members) == 0 {
+		return g, fmt.Errorf("策略组 %s 没有成员", g.Name)
+	}
+	return g, nil
+}
+
+// groupOption 识别 !!name=value 形式的健康检查参数，返回参数名
+func groupOption(m string) (string, bool) {
+	if !strings.HasPrefix(m, "!!") {
+		return "", false
+	}
+	idx := strings.Index(m, "=")
+	if idx < 0 {
+		return "", false
+	}
+	name := m[2:idx]
+	switch name {
+	case "lazy", "max-failed-times", "strategy":
+		return name, true
+	}
+	return "", false
+}
+
+func (g *GroupTemplate) setOption(name, value string) error {
+	switch name {
+	case "lazy":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("lazy 应为 true 或 false: %s", value)
+		}
+		g.Lazy = &v
+	case "max-failed-times":
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 {
+			return fmt.Errorf("max-failed-times 应为非负整数: %s", value)
+		}
+		g.MaxFailedTimes = v
+	case "strategy":
+		switch value {
+		case "consistent-hashing", "round-robin", "sticky-sessions":
+		default:
+			return fmt.Errorf("strategy 应为 consistent-hashing、round-robin 或 sticky-sessions: %s", value)
+		}
+		g.Strategy = value
+	}
+	return nil
+}
+
+// loadModeTemplates 读取内置模板，并用 dir 中的同名 .ini 覆盖或追加新模式
+func loadModeTemplates(dir string) ([]*ModeTemplate, error) {
+	files := map[string][]byte{}
+	entries, err := templateFS.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		b, err := templateFS.ReadFile("templates/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		files[e.Name()] = b
+	}
+	if dir != "" {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.ini"))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			b, err := os.ReadFile(m)
+			if err != nil {
+				return nil, err
+			}
+			files[filepath.Base(m)] = b
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var modes []*ModeTemplate
+	for _, n := range names {
+		t, err := parseModeTemplate(n, files[n])
+		if err != nil {
+			return nil, err
+		}
+		modes = append(modes, t)
+	}
+	return modes, nil
+}
+
+// defaultModeIndex 返回标记为 mode_default 的模式序号
+func defaultModeIndex(modes []*ModeTemplate) int {
+	for i, m := range modes {
+		if m.Default {
+			return i
+		}
+	}
+	return 0
+}
+
+// roleForURL 把 ACL4SSR 的列表地址映射回规则角色，使 subconverter 模板也能切换规则源
+func roleForURL(u string) string {
+	for role, ls := range ruleSources[0].Lists {
+		for _, l := range ls {
+			if l.URL == u {
+				return role
+			}
+		}
+	}
+	return ""
+}
+
+// resolveRuleset 把模板中的规则来源解析为具体规则列表；
+// 内联规则返回 inline，取不到列表的角色返回空
+func resolveRuleset(source string, src *RuleSource) (lists []RuleList, role string, inline string) {
+	if strings.HasPrefix(source, "[]") {
+		return nil, "", source[2:]
+	}
+	// 去掉 subconverter 的更新间隔参数: URL,86400
+	if idx := strings.LastIndex(source, ","); idx > 0 {
+		if _, err := strconv.Atoi(source[idx+1:]); err == nil {
+			source = source[:idx]
+		}
+	}
+	switch {
+	case strings.HasPrefix(source, "source:"):
+		role = strings.TrimPrefix(source, "source:")
+		return src.Lists[role], role, ""
+	case strings.HasPrefix(source, "clash-domain:"):
+		return []RuleList{{URL: strings.TrimPrefix(source, "clash-domain:"), Format: "yaml", Behavior: "domain"}}, "", ""
+	case strings.HasPrefix(source, "clash-ipcidr:"):
+		return []RuleList{{URL: strings.TrimPrefix(source, "clash-ipcidr:"), Format: "yaml", Behavior: "ipcidr"}}, "", ""
+	case strings.HasPrefix(source, "clash-classic:"):
+		return []RuleList{{URL: strings.TrimPrefix(source, "clash-classic:"), Format: "yaml", Behavior: "classical"}}, "", ""
+	}
+	if role = roleForURL(source); role != "" {
+		return src.Lists[role], role, ""
+	}
+	return []RuleList{{URL: source, Format: "text", Behavior: "classical"}}, "", ""
+}
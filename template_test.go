@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGroupTemplate(t *testing.T) {
+	yes := true
+	tests := []struct {
+		spec string
+		want GroupTemplate
+		err  string
+	}{
+		{
+			spec: "🚀 节点选择`select`[]♻️ 自动选择`[]DIRECT`.*",
+			want: GroupTemplate{Name: "🚀 节点选择", Type: "select", Members: []string{"[]♻️ 自动选择", "[]DIRECT", ".*"}},
+		},
+		{
+			spec: "♻️ 自动选择`url-test`.*`http://www.gstatic.com/generate_204`300,5,50",
+			want: GroupTemplate{Name: "♻️ 自动选择", Type: "url-test", Members: []string{".*"}, URL: "http://www.gstatic.com/generate_204", Interval: 300, Timeout: 5, Tolerance: 50},
+		},
+		{
+			spec: "🔯 故障转移`fallback`!!REGIONS`https://cp.cloudflare.com`180",
+			want: GroupTemplate{Name: "🔯 故障转移", Type: "fallback", Members: []string{"!!REGIONS"}, URL: "https://cp.cloudflare.com", Interval: 180},
+		},
+		{
+			spec: "⚖️ 负载均衡`load-balance`.*`!!lazy=true`!!max-failed-times=3`!!strategy=round-robin`http://t.co`300,,",
+			want: GroupTemplate{Name: "⚖️ 负载均衡", Type: "load-balance", Members: []string{".*"}, URL: "http://t.co", Interval: 300, Lazy: &yes, MaxFailedTimes: 3, Strategy: "round-robin"},
+		},
+		{spec: "只有名称`select", err: "至少需要"},
+		{spec: "组`relay`.*", err: "不支持的策略组类型"},
+		{spec: "组`select``  ", err: "没有成员"},
+		{spec: "组`url-test`.*`http://t.co`abc", err: "测速参数"},
+		{spec: "组`url-test`.*`!!lazy=maybe", err: "lazy"},
+		{spec: "组`load-balance`.*`!!strategy=random", err: "strategy"},
+		{spec: "组`fallback`.*`!!max-failed-times=-1", err: "max-failed-times"},
+	}
+	for _, tt := range tests {
+		got, err := parseGroupTemplate(tt.spec)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseGroupTemplate(%q) 错误 %v，应包含 %q", tt.spec, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseGroupTemplate(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseGroupTemplate(%q)\n得到 %+v\n应为 %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseModeTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want *ModeTemplate
+		err  string
+	}{
+		{
+			name: "完整模板",
+			file: "10_Custom.ini",
+			data: "[custom]\nmode_name=自定义\nmode_desc=测试用\nmode_default=true\n" +
+				"ruleset=🎯 全球直连,https://example.com/a.list\n" +
+				"ruleset=🎯 全球直连,[]GEOIP,CN\n" +
+				"ruleset=🐟 漏网之鱼,[]FINAL\n" +
+				"custom_proxy_group=🐟 漏网之鱼`select`[]DIRECT`.*\n",
+			want: &ModeTemplate{
+				File: "10_Custom.ini", Name: "自定义", Desc: "测试用", Default: true,
+				Groups: []GroupTemplate{{Name: "🐟 漏网之鱼", Type: "select", Members: []string{"[]DIRECT", ".*"}}},
+				Rulesets: []RulesetTemplate{
+					{Group: "🎯 全球直连", Source: "https://example.com/a.list"},
+					{Group: "🎯 全球直连", Source: "[]GEOIP,CN"},
+					{Group: "🐟 漏网之鱼", Source: "[]FINAL"},
+				},
+			},
+		},
+		{
+			name: "名称和说明默认取文件名",
+			file: "11_Mini.ini",
+			data: "[custom]\ncustom_proxy_group=A`select`.*\n",
+			want: &ModeTemplate{File: "11_Mini.ini", Name: "11_Mini", Desc: "11_Mini", Groups: []GroupTemplate{{Name: "A", Type: "select", Members: []string{".*"}}}},
+		},
+		{
+			name: "Provider 模板不需要策略组",
+			file: "00_Provider.ini",
+			data: "[custom]\nmode_name=Provider\nprovider_only=true\n",
+			want: &ModeTemplate{File: "00_Provider.ini", Name: "Provider", Desc: "Provider", ProviderOnly: true},
+		},
+		{name: "没有策略组", file: "x.ini", data: "[custom]\nruleset=A,[]FINAL\n", err: "没有定义任何 custom_proxy_group"},
+		{name: "ruleset 缺少策略组", file: "x.ini", data: "[custom]\nruleset=https://example.com/a.list\ncustom_proxy_group=A`select`.*\n", err: "第 2 行"},
+		{name: "策略组错误带行号", file: "x.ini", data: "[custom]\n\ncustom_proxy_group=A`select\n", err: "第 3 行"},
+	}
+	for _, tt := range tests {
+		got, err := parseModeTemplate(tt.file, []byte(tt.data))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: 错误 %v，应包含 %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n得到 %+v\n应为 %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	modes, err := loadModeTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := 0
+	for _, m := range modes {
+		if m.Default {
+			defaults++
+		}
+	}
+	if len(modes) == 0 || defaults != 1 {
+		t.Errorf("内置模板 %d 个，其中默认模式 %d 个，应恰好 1 个", len(modes), defaults)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// --- 配置校验 ---
+
+// Issue 是校验发现的一个问题；Error 为 true 时 Clash 会拒绝加载或配置不可用，不写入文件
+type Issue struct {
+	Error bool
+	Where string // 如 节点 HK01、策略组 🚀 节点选择、规则 #12
+	Msg   string
+}
+
+func (i Issue) String() string {
+	return i.Where + ": " + i.Msg
+}
+
+// 内联节点的 flow 写法中这些字符会破坏 YAML 结构
+const unsafeNameChars = ",{}[]#\"'"
+
+// validateConfig 在写入前检查生成结果：节点必填字段与端口、名称冲突、
+// 策略组成员与节点集引用、空组和循环引用、规则语法与策略组引用、MATCH 位置
+func validateConfig(cfg *ClashConfig) []Issue {
+	var issues []Issue
+	add := func(err bool, where, format string, args ...interface{}) {
+		issues = append(issues, Issue{Error: err, Where: where, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	// --- 节点 ---
+	names := map[string]string{} // 名称 → 种类 (节点/策略组)
+	for i, n := range cfg.Proxies {
+		where := "节点 " + n.Name
+		if n.Name == "" {
+			where = fmt.Sprintf("第 %d 个节点", i+1)
+			add(true, where, "名称为空")
+		} else if names[n.Name] != "" {
+			add(true, where, "名称重复")
+		} else if strings.ContainsAny(n.Name, unsafeNameChars) || strings.Contains(n.Name, ": ") {
+			add(true, where, "名称含有 %s 或 \": \"，会破坏 YAML 结构", unsafeNameChars)
+		}
+		names[n.Name] = "节点"
+		validateNode(n, func(format string, args ...interface{}) { add(true, where, format, args...) })
+	}
+	if cfg.ProviderOnly {
+		return issues
+	}
+
+	// --- 策略组 ---
+	providers := map[string]bool{}
+	for _, p := range cfg.ProxyProviders {
+		providers[p.Name] = true
+	}
+	groups := map[string]ProxyGroup{}
+	for _, g := range cfg.Groups {
+		where := "策略组 " + g.Name
+		switch {
+		case g.Name == "":
+			add(true, "策略组", "名称为空")
+		case names[g.Name] == "节点":
+			add(true, where, "与节点重名")
+		case names[g.Name] != "":
+			add(true, where, "名称重复")
+		case builtinPolicies[g.Name]:
+			add(true, where, "与内置策略重名")
+		}
+		names[g.Name] = "策略组"
+		groups[g.Name] = g
+		switch g.Type {
+		case "select", "url-test", "fallback", "load-balance", "relay":
+		default:
+			add(true, where, "未知的类型 %q", g.Type)
+		}
+		if g.Type != "select" && g.Type != "relay" && g.URL == "" {
+			add(true, where, "%s 组缺少测速地址", g.Type)
+		}
+		if len(g.Proxies) == 0 && len(g.Use) == 0 {
+			add(true, where, "没有任何成员")
+		}
+		for _, u := range g.Use {
+			if !providers[u] {
+				add(true, where, "引用的节点集 %q 不存在", u)
+			}
+		}
+	}
+	for _, g := range cfg.Groups {
+		where := "策略组 " + g.Name
+		seen := map[string]bool{}
+		for _, m := range g.Proxies {
+			switch {
+			case m == g.Name:
+				add(true, where, "引用了自身")
+			case names[m] == "" && !builtinPolicies[m]:
+				add(true, where, "成员 %q 不存在", m)
+			case seen[m]:
+				add(false, where, "成员 %q 重复", m)
+			}
+			seen[m] = true
+		}
+	}
+	if loop := findGroupLoop(cfg.Groups, groups); loop != nil {
+		add(true, "策略组", "循环引用: %s", strings.Join(loop, " → "))
+	}
+
+	// --- 规则 ---
+	// 规则列表全部缺失时配置只剩自定义规则和 MATCH，所有流量都走兜底策略
+	if cfg.RuleLists > 0 && len(cfg.MissingLists) == cfg.RuleLists {
+		add(true, "规则", "全部 %d 个规则列表都获取失败，请联网或用 -cache-dir 指定已有缓存后重试", cfg.RuleLists)
+	}
+	ruleSets := map[string]bool{}
+	for _, l := range cfg.RuleProviders {
+		ruleSets[ruleProviderName(l.URL)] = true
+	}
+	matchAt := 0
+	for i, r := range cfg.Rules {
+		where := fmt.Sprintf("规则 #%d %s", i+1, r.String())
+		if matchAt > 0 {
+			add(false, where, "位于第 %d 条 MATCH 之后，永远不会命中", matchAt)
+			break
+		}
+		if _, err := parseRule(r.String(), true); err != nil {
+			add(true, where, "语法错误: %v", err)
+			continue
+		}
+		if names[r.Target] != "策略组" && !builtinPolicies[r.Target] {
+			add(true, where, "策略组 %q 不存在", r.Target)
+		}
+		if r.Type == "RULE-SET" && !ruleSets[r.Value] {
+			add(true, where, "rule-provider %q 不存在", r.Value)
+		}
+		if r.Type == "MATCH" {
+			matchAt = i + 1
+		}
+	}
+	if len(cfg.Rules) > 0 && matchAt == 0 {
+		add(false, "规则", "缺少兜底 MATCH 规则，未命中的连接将直连")
+	}
+	return issues
+}
+
+// validateNode 检查各协议的必填字段和端口
+func validateNode(n Node, fail func(format string, args ...interface{})) {
+	if n.Server == "" {
+		fail("缺少服务器地址")
+	}
+	if p, err := strconv.Atoi(n.Port); err != nil || p < 1 || p > 65535 {
+		fail("端口 %q 无效", n.Port)
+	}
+	switch n.Type {
+	case "vless":
+		if n.UUID == "" {
+			fail("缺少 UUID")
+		}
+	case "hysteria2":
+		if n.Password == "" {
+			fail("缺少密码")
+		}
+	case "ss":
+		if n.Cipher == "" {
+			fail("缺少加密方式")
+		}
+		if n.Password == "" {
+			fail("缺少密码")
+		}
+	default:
+		fail("不支持的类型 %q", n.Type)
+	}
+}
+
+// findGroupLoop 查找策略组之间的循环引用，返回环上的组名 (首尾相同)，没有环时返回 nil
+func findGroupLoop(list []ProxyGroup, groups map[string]ProxyGroup) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, m := range groups[name].Proxies {
+			if _, ok := groups[m]; !ok || m == name {
+				continue
+			}
+			switch state[m] {
+			case visiting:
+				for i, s := range stack {
+					if s == m {
+						return append(append([]string(nil), stack[i:]...), m)
+					}
+				}
+			case unvisited:
+				if loop := visit(m); loop != nil {
+					return loop
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return nil
+	}
+	for _, g := range list {
+		if state[g.Name] == unvisited {
+			if loop := visit(g.Name); loop != nil {
+				return loop
+			}
+		}
+	}
+	return nil
+}
+
+// printIssues 输出校验结果，返回错误数量
+func printIssues(issues []Issue) int {
+	errs := 0
+	for _, i := range issues {
+		if i.Error {
+			errs++
+			fmt.Printf("   ❌ %s\n", i)
+		} else {
+			fmt.Printf("   ⚠️  %s\n", i)
+		}
+	}
+	return errs
+}
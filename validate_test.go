@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// validConfig 返回一份能通过校验的最小配置，各用例在此基础上改出问题
+func validConfig() *ClashConfig {
+	return &ClashConfig{
+		Proxies: []Node{
+			{Type: "ss", Name: "香港 01", Server: "a.example.com", Port: "8388", Password: "p", Cipher: "aes-128-gcm"},
+			{Type: "vless", Name: "日本 01", Server: "b.example.com", Port: "443", UUID: "u"},
+		},
+		Groups: []ProxyGroup{
+			{Name: "🚀 节点选择", Type: "select", Proxies: []string{"♻️ 自动选择", "香港 01", "日本 01", "DIRECT"}},
+			{Name: "♻️ 自动选择", Type: "url-test", URL: "http://www.gstatic.com/generate_204", Proxies: []string{"香港 01", "日本 01"}},
+		},
+		Rules: []Rule{
+			{Type: "DOMAIN-SUFFIX", Value: "cn", Target: "DIRECT"},
+			{Type: "GEOIP", Value: "CN", Target: "DIRECT"},
+			{Type: "MATCH", Target: "🚀 节点选择"},
+		},
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	if issues := validateConfig(validConfig()); len(issues) != 0 {
+		t.Fatalf("合法配置报告了问题: %v", issues)
+	}
+	tests := []struct {
+		name   string
+		modify func(*ClashConfig)
+		err    bool
+		want   string
+	}{
+		{"节点重名", func(c *ClashConfig) { c.Proxies[1].Name = "香港 01" }, true, "节点 香港 01: 名称重复"},
+		{"节点名含逗号", func(c *ClashConfig) { c.Proxies[0].Name = "香港,01" }, true, "会破坏 YAML 结构"},
+		{"节点名含冒号空格", func(c *ClashConfig) { c.Proxies[0].Name = "香港: 01" }, true, "会破坏 YAML 结构"},
+		{"节点端口无效", func(c *ClashConfig) { c.Proxies[0].Port = "70000" }, true, "端口 \"70000\" 无效"},
+		{"vless 缺少 UUID", func(c *ClashConfig) { c.Proxies[1].UUID = "" }, true, "缺少 UUID"},
+		{"策略组与节点重名", func(c *ClashConfig) { c.Groups[1].Name = "日本 01" }, true, "与节点重名"},
+		{"策略组与内置策略重名", func(c *ClashConfig) { c.Groups[1].Name = "DIRECT" }, true, "与内置策略重名"},
+		{"url-test 缺少测速地址", func(c *ClashConfig) { c.Groups[1].URL = "" }, true, "url-test 组缺少测速地址"},
+		{"未知的组类型", func(c *ClashConfig) { c.Groups[0].Type = "manual" }, true, "未知的类型 \"manual\""},
+		{"空组", func(c *ClashConfig) { c.Groups[1].Proxies = nil }, true, "没有任何成员"},
+		{"成员不存在", func(c *ClashConfig) { c.Groups[1].Proxies = append(c.Groups[1].Proxies, "美国 01") }, true, "成员 \"美国 01\" 不存在"},
+		{"成员重复", func(c *ClashConfig) { c.Groups[1].Proxies = append(c.Groups[1].Proxies, "香港 01") }, false, "成员 \"香港 01\" 重复"},
+		{"引用自身", func(c *ClashConfig) { c.Groups[1].Proxies = append(c.Groups[1].Proxies, "♻️ 自动选择") }, true, "引用了自身"},
+		{"节点集不存在", func(c *ClashConfig) { c.Groups[1].Use = []string{"机场A"} }, true, "引用的节点集 \"机场A\" 不存在"},
+		{"节点集存在", func(c *ClashConfig) {
+			c.ProxyProviders = []ProxyProvider{{Name: "机场A"}}
+			c.Groups[1].Use = []string{"机场A"}
+		}, false, ""},
+		{"循环引用", func(c *ClashConfig) { c.Groups[1].Proxies = append(c.Groups[1].Proxies, "🚀 节点选择") }, true, "循环引用: 🚀 节点选择 → ♻️ 自动选择 → 🚀 节点选择"},
+		{"规则引用的策略组不存在", func(c *ClashConfig) { c.Rules[0].Target = "🎯 全球直连" }, true, "策略组 \"🎯 全球直连\" 不存在"},
+		{"RULE-SET 没有对应的 rule-provider", func(c *ClashConfig) {
+			c.Rules = append([]Rule{{Type: "RULE-SET", Value: "LocalAreaNetwork", Target: "DIRECT"}}, c.Rules...)
+		}, true, "rule-provider \"LocalAreaNetwork\" 不存在"},
+		{"规则语法错误", func(c *ClashConfig) { c.Rules[1].Value = "" }, true, "语法错误"},
+		{"MATCH 之后还有规则", func(c *ClashConfig) {
+			c.Rules = append(c.Rules, Rule{Type: "DOMAIN", Value: "a.com", Target: "DIRECT"})
+		}, false, "位于第 3 条 MATCH 之后，永远不会命中"},
+		{"缺少 MATCH", func(c *ClashConfig) { c.Rules = c.Rules[:2] }, false, "缺少兜底 MATCH 规则"},
+		{"部分规则列表缺失", func(c *ClashConfig) {
+			c.RuleLists, c.MissingLists = 3, []string{"BanAD"}
+		}, false, ""},
+		{"全部规则列表缺失", func(c *ClashConfig) {
+			c.RuleLists, c.MissingLists = 2, []string{"BanAD", "ChinaDomain"}
+		}, true, "全部 2 个规则列表都获取失败"},
+		{"只输出节点集时不检查策略组和规则", func(c *ClashConfig) {
+			c.ProviderOnly = true
+			c.Groups, c.Rules = nil, nil
+		}, false, ""},
+	}
+	for _, tt := range tests {
+		cfg := validConfig()
+		tt.modify(cfg)
+		issues := validateConfig(cfg)
+		if tt.want == "" {
+			if len(issues) != 0 {
+				t.Errorf("%s: 不应报告问题: %v", tt.name, issues)
+			}
+			continue
+		}
+		found := false
+		for _, i := range issues {
+			if strings.Contains(i.String(), tt.want) {
+				found = true
+				if i.Error != tt.err {
+					t.Errorf("%s: %q 的 Error 为 %v，应为 %v", tt.name, i, i.Error, tt.err)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: 没有报告 %q，实际: %v", tt.name, tt.want, issues)
+		}
+	}
+}
+
+func TestFindGroupLoop(t *testing.T) {
+	mk := func(spec map[string][]string, order ...string) []string {
+		var list []ProxyGroup
+		groups := map[string]ProxyGroup{}
+		for _, name := range order {
+			g := ProxyGroup{Name: name, Proxies: spec[name]}
+			list = append(list, g)
+			groups[name] = g
+		}
+		return findGroupLoop(list, groups)
+	}
+	tests := []struct {
+		name  string
+		spec  map[string][]string
+		order []string
+		want  []string
+	}{
+		{
+			name:  "树状引用",
+			spec:  map[string][]string{"A": {"B", "C", "DIRECT"}, "B": {"C", "hk"}, "C": {"hk"}},
+			order: []string{"A", "B", "C"},
+		},
+		{
+			name:  "自身引用由成员检查报告",
+			spec:  map[string][]string{"A": {"A", "hk"}},
+			order: []string{"A"},
+		},
+		{
+			name:  "两个组互相引用",
+			spec:  map[string][]string{"A": {"B"}, "B": {"A"}},
+			order: []string{"A", "B"},
+			want:  []string{"A", "B", "A"},
+		},
+		{
+			name:  "环不包含起点",
+			spec:  map[string][]string{"A": {"B"}, "B": {"C"}, "C": {"D"}, "D": {"B"}},
+			order: []string{"A", "B", "C", "D"},
+			want:  []string{"B", "C", "D", "B"},
+		},
+	}
+	for _, tt := range tests {
+		if got := mk(tt.spec, tt.order...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: 返回 %q，应为 %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- 守护模式 ---
+
+// Watcher 定时或在本地输入文件变化时重新生成配置，内容不变时不写文件
+type Watcher struct {
+	Sources    []Source
+	Mode       ModeConfig
+	RulesFile  string // 自定义规则文件，每行一条，写法同步骤 2
+	Output     string
+	Interval   time.Duration
+	Fetcher    *RuleFetcher
+	GeoIP      *geoIP
+	Controller *Controller // 非 nil 时写入新配置后推送到 Clash 并核对加载结果
+	Backups    int         // 覆盖前保留的备份数量
+
+	files map[string]fileStamp // 监视的本地文件及上次的修改时间/大小
+}
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// 本地文件变化的检查间隔
+const watchPollInterval = 5 * time.Second
+
+// Run 先生成一次，之后一直运行；单次生成失败只打印错误，保留上一次的配置
+func (w *Watcher) Run() error {
+	if len(w.Sources) == 0 && len(w.Mode.ProxyProviders) == 0 {
+		return fmt.Errorf("守护模式需要在 [sources] 或 -source 中配置节点来源")
+	}
+	w.files = map[string]fileStamp{}
+	for _, src := range w.Sources {
+		if !strings.Contains(src.Spec, "://") {
+			w.files[src.Spec] = statFile(src.Spec)
+		}
+	}
+	if w.RulesFile != "" {
+		w.files[w.RulesFile] = statFile(w.RulesFile)
+	}
+
+	fmt.Printf("👀 守护模式：每 %v 重新生成 %s", w.Interval, w.Output)
+	if len(w.files) > 0 {
+		fmt.Printf("，%d 个本地文件变化时立即生成", len(w.files))
+	}
+	fmt.Println()
+	w.regenerate("启动")
+	last := time.Now()
+	for range time.Tick(watchPollInterval) {
+		if changed := w.changedFiles(); len(changed) > 0 {
+			w.regenerate(strings.Join(changed, ", ") + " 已修改")
+			last = time.Now()
+		} else if time.Since(last) >= w.Interval {
+			w.regenerate("定时更新")
+			last = time.Now()
+		}
+	}
+	return nil
+}
+
+func statFile(path string) fileStamp {
+	st, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{mod: st.ModTime(), size: st.Size()}
+}
+
+func (w *Watcher) changedFiles() []string {
+	var changed []string
+	for path, old := range w.files {
+		if cur := statFile(path); cur != old {
+			w.files[path] = cur
+			changed = append(changed, filepath.Base(path))
+		}
+	}
+	return changed
+}
+
+func (w *Watcher) regenerate(reason string) {
+	fmt.Printf("\n[%s] %s，重新生成...\n", time.Now().Format("2006-01-02 15:04:05"), reason)
+	cfg, err := w.generate()
+	if err != nil {
+		fmt.Printf("❌ %v，保留现有配置\n", err)
+		return
+	}
+	content := renderConfig(cfg)
+	old, _ := os.ReadFile(w.Output)
+	if bytes.Equal(old, []byte(content)) {
+		fmt.Println("✅ 配置没有变化")
+		return
+	}
+	if old != nil {
+		for _, line := range diffSummary(string(old), content) {
+			fmt.Println("   " + line)
+		}
+	}
+	if _, err := replaceOutput(w.Output, []byte(content), w.Backups); err != nil {
+		fmt.Printf("❌ 写入失败: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ 已更新 %s\n", w.Output)
+	if w.Mode.RuleProviderType == "file" && !w.Mode.IsProvider {
+		if _, err := w.Fetcher.saveRuleSets(filepath.Dir(w.Output)); err != nil {
+			fmt.Printf("❌ 写入 ruleset 目录失败: %v\n", err)
+		}
+	}
+	if w.Controller != nil {
+		pushConfig(w.Controller, w.Output, cfg, content)
+	}
+}
+
+// generate 读取全部来源并生成配置；任一来源读取失败都放弃本次生成，避免节点缺失的配置覆盖正常配置
+func (w *Watcher) generate() (*ClashConfig, error) {
+	var nodes []Node
+	var subs []*Subscription
+	for _, src := range w.Sources {
+		res, err := loadSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %v", src.Title(), err)
+		}
+		nodes = append(nodes, res.Nodes...)
+		if res.Sub != nil {
+			subs = append(subs, res.Sub)
+		}
+	}
+	nodes, _, _ = dedupeNodes(nodes)
+	if len(nodes) == 0 && len(w.Mode.ProxyProviders) == 0 {
+		return nil, fmt.Errorf("没有解析到有效节点")
+	}
+	if w.GeoIP != nil {
+		resolveRegions(nodes, w.GeoIP)
+	}
+
+	var customRules []CustomRule
+	if w.RulesFile != "" {
+		b, err := os.ReadFile(w.RulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取自定义规则失败: %v", err)
+		}
+		var errs []error
+		customRules, errs = parseCustomRules(strings.Split(string(b), "\n"))
+		for _, err := range errs {
+			fmt.Printf("   ❌ %v\n", err)
+		}
+	}
+
+	c := w.Mode
+	c.Comments = append([]string(nil), c.Comments...)
+	nodes = applyUserInfo(&c, nodes, subs)
+	fmt.Printf("📦 %d 个节点，模式 %s\n", len(nodes), c.Name)
+	cfg := buildConfig(nodes, c, customRules, w.Fetcher)
+	if n := printIssues(validateConfig(cfg)); n > 0 {
+		return nil, fmt.Errorf("生成的配置有 %d 个错误", n)
+	}
+	return cfg, nil
+}